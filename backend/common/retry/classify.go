@@ -0,0 +1,91 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// mysqlRetryableErrorNumbers are the MySQL/TiDB error codes worth retrying:
+// 1213 (deadlock found when trying to get lock) and 2006 (server has gone
+// away, typically an idle connection getting dropped mid-transaction).
+var mysqlRetryableErrorNumbers = map[uint16]bool{
+	1213: true,
+	2006: true,
+}
+
+// mssqlRetryableErrorNumbers are the MSSQL error codes worth retrying: 1205
+// (transaction chosen as deadlock victim).
+var mssqlRetryableErrorNumbers = map[int32]bool{
+	1205: true,
+}
+
+// postgresRetryableSQLStates are the Postgres SQLSTATE codes worth retrying:
+// 40001 (serialization_failure) and 40P01 (deadlock_detected).
+var postgresRetryableSQLStates = map[string]bool{
+	"40001": true,
+	"40P01": true,
+}
+
+// IsRetryableDBError reports whether err, returned by a driver call against
+// engine, represents a transient failure (deadlock, serialization failure,
+// dropped connection) worth retrying rather than a deterministic one (bad SQL,
+// constraint violation) that would just fail again.
+func IsRetryableDBError(engine storepb.Engine, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB:
+		var mysqlErr *mysqldriver.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlRetryableErrorNumbers[mysqlErr.Number] {
+			return true
+		}
+	case storepb.Engine_MSSQL:
+		var mssqlErr mssql.Error
+		if errors.As(err, &mssqlErr) && mssqlRetryableErrorNumbers[mssqlErr.Number] {
+			return true
+		}
+	case storepb.Engine_POSTGRES:
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && postgresRetryableSQLStates[pgErr.Code] {
+			return true
+		}
+	}
+
+	return isRetryableConnectionError(err)
+}
+
+// isRetryableConnectionError catches the engine-agnostic case: the
+// connection itself dropped (reset, timed out, closed) rather than the
+// statement failing for a SQL reason.
+func isRetryableConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	msg := err.Error()
+	for _, substr := range []string{
+		"connection reset by peer",
+		"broken pipe",
+		"driver: bad connection",
+		"connection refused",
+		"i/o timeout",
+	} {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
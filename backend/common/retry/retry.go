@@ -0,0 +1,119 @@
+// Package retry provides a generic, context-aware retry helper for wrapping
+// external calls (database drivers, remote APIs) that can fail transiently.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMaxAttempts and defaultBaseDelay are used when Options leaves the
+// corresponding field unset (zero value).
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 200 * time.Millisecond
+	defaultMaxDelay    = 10 * time.Second
+)
+
+// Options configures a single Do call. The zero value is usable and falls
+// back to defaultMaxAttempts/defaultBaseDelay/defaultMaxDelay.
+type Options struct {
+	// MaxAttempts is the total number of times fn is invoked, including the
+	// first attempt. Values <= 0 fall back to defaultMaxAttempts.
+	MaxAttempts int
+	// BaseDelay is the delay before the second attempt; each subsequent
+	// retry doubles it, up to MaxDelay. Values <= 0 fall back to
+	// defaultBaseDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay. Values <= 0 fall back to
+	// defaultMaxDelay.
+	MaxDelay time.Duration
+	// IsRetryable decides whether an error returned by fn should trigger
+	// another attempt. A nil IsRetryable means no error is retryable, i.e.
+	// Do behaves like a single call to fn.
+	IsRetryable func(error) bool
+	// BeforeRetry runs right before each retry (not before the first
+	// attempt), e.g. to drop a half-created backup table so the next
+	// attempt starts from a clean slate. A BeforeRetry failure aborts the
+	// retry loop and is returned wrapped around the original error.
+	BeforeRetry func(ctx context.Context) error
+}
+
+// Do calls fn, retrying with exponential backoff and jitter while
+// opts.IsRetryable(err) reports true and attempts remain. It stops early and
+// returns ctx.Err() if ctx is canceled, including while waiting out a
+// backoff delay.
+func Do(ctx context.Context, opts Options, fn func(ctx context.Context) error) error {
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	baseDelay := opts.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultBaseDelay
+	}
+	maxDelay := opts.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			if opts.BeforeRetry != nil {
+				if err := opts.BeforeRetry(ctx); err != nil {
+					return errors.Wrapf(lastErr, "retry cleanup failed after attempt %d: %v", attempt-1, err)
+				}
+			}
+			if err := sleep(ctx, backoffDelay(baseDelay, maxDelay, attempt-2)); err != nil {
+				return err
+			}
+		}
+
+		lastErr = fn(ctx)
+		if lastErr == nil {
+			return nil
+		}
+		if opts.IsRetryable == nil || !opts.IsRetryable(lastErr) {
+			return lastErr
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return errors.Wrapf(lastErr, "gave up after %d attempts", maxAttempts)
+}
+
+// sleep waits for d, or returns ctx.Err() if ctx is canceled first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoffDelay returns base doubled retryNumber times, capped at max, with up
+// to 25% jitter added so concurrent callers don't retry in lockstep.
+func backoffDelay(base time.Duration, max time.Duration, retryNumber int) time.Duration {
+	delay := base
+	for i := 0; i < retryNumber; i++ {
+		delay *= 2
+		if delay >= max {
+			delay = max
+			break
+		}
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/4 + 1))
+	delay += jitter
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
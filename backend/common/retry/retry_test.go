@@ -0,0 +1,48 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	t.Run("never exceeds max even with jitter", func(t *testing.T) {
+		base := 100 * time.Millisecond
+		max := 1 * time.Second
+		for retryNumber := 0; retryNumber < 10; retryNumber++ {
+			for i := 0; i < 20; i++ {
+				d := backoffDelay(base, max, retryNumber)
+				if d > max {
+					t.Fatalf("retryNumber=%d: backoffDelay = %v, want <= max %v", retryNumber, d, max)
+				}
+				if d < base {
+					t.Fatalf("retryNumber=%d: backoffDelay = %v, want >= base %v", retryNumber, d, base)
+				}
+			}
+		}
+	})
+
+	t.Run("doubles with each retry before hitting the cap", func(t *testing.T) {
+		base := 100 * time.Millisecond
+		max := 10 * time.Second
+		d0 := backoffDelay(base, max, 0)
+		d1 := backoffDelay(base, max, 1)
+		// d1's un-jittered delay is double d0's, and jitter is at most 25% of
+		// that delay, so d1 must fall within [2*base, 2*base*5/4].
+		if d1 < 2*base || d1 > 2*base*5/4 {
+			t.Errorf("backoffDelay(retryNumber=1) = %v, want within [%v, %v]", d1, 2*base, 2*base*5/4)
+		}
+		if d0 < base || d0 > base*5/4 {
+			t.Errorf("backoffDelay(retryNumber=0) = %v, want within [%v, %v]", d0, base, base*5/4)
+		}
+	})
+
+	t.Run("saturates at max once doubling exceeds it", func(t *testing.T) {
+		base := 100 * time.Millisecond
+		max := 150 * time.Millisecond
+		// 100ms doubled 3 times is 800ms, well past the 150ms cap.
+		if d := backoffDelay(base, max, 3); d != max {
+			t.Errorf("backoffDelay = %v, want exactly max %v once the un-jittered delay has saturated", d, max)
+		}
+	})
+}
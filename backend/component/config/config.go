@@ -0,0 +1,50 @@
+// Package config holds the runtime-tunable settings that task executors read
+// to decide how to back up data, retry transient failures, and shard large
+// DML statements. It is kept separate from command-line flag parsing so
+// executors can depend on a plain struct instead of a flag package.
+package config
+
+import "time"
+
+// Profile is the set of operator-tunable knobs the task runners consult.
+// The zero value is usable: every field falls back to a safe default where
+// one is documented below.
+type Profile struct {
+	// PreUpdateBackupChecksumMode controls how a mismatch between a
+	// pre-update backup table and the source rows it was copied from is
+	// handled. The zero value is PreUpdateBackupChecksumModeStrict.
+	PreUpdateBackupChecksumMode PreUpdateBackupChecksumMode
+
+	// BackupRetryMaxAttempts and BackupRetryBaseDelay configure the retry
+	// behavior of every external driver call made while backing up or
+	// migrating a database (see retryOptions in the taskrun package). Values
+	// <= 0 fall back to the defaults in the retry package.
+	BackupRetryMaxAttempts int
+	BackupRetryBaseDelay   time.Duration
+
+	// DMLShardingRowCountThreshold is the minimum number of rows a DML
+	// statement must affect before it is dispatched as sharded subtasks
+	// instead of running as a single statement. A value <= 0 disables
+	// sharding entirely.
+	DMLShardingRowCountThreshold int64
+	// DMLShardingCount is the number of shards a qualifying DML statement is
+	// split into. Values < 1 are treated as 1 shard.
+	DMLShardingCount int
+}
+
+// PreUpdateBackupChecksumMode controls how verifyBackupChecksum reacts to a
+// mismatch between a source table and the backup table just materialized
+// from it.
+type PreUpdateBackupChecksumMode int
+
+const (
+	// PreUpdateBackupChecksumModeStrict aborts the task run on a checksum
+	// mismatch. This is the zero value, so an unconfigured Profile defaults
+	// to the safest behavior.
+	PreUpdateBackupChecksumModeStrict PreUpdateBackupChecksumMode = iota
+	// PreUpdateBackupChecksumModeBestEffort logs a warning on mismatch but
+	// lets the task run continue.
+	PreUpdateBackupChecksumModeBestEffort
+	// PreUpdateBackupChecksumModeOff skips checksum verification entirely.
+	PreUpdateBackupChecksumModeOff
+)
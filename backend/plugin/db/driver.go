@@ -0,0 +1,82 @@
+// Package db declares the admin database driver surface the backup, undo-log,
+// and sharded-dispatch features in backend/runner/taskrun depend on. It holds
+// only the interface and the small option/value types those callers pass
+// through; concrete per-engine drivers (MySQL, TiDB, MSSQL, Postgres, ...)
+// live outside this package and are obtained through dbfactory.
+package db
+
+import (
+	"context"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+)
+
+// ConnectionContext carries per-connection metadata (read-only replica
+// routing, connection pool tagging, ...) through to dbfactory when opening a
+// driver. It is deliberately empty here: taskrun only ever passes the zero
+// value today, and any fields dbfactory needs live alongside the rest of
+// that component.
+type ConnectionContext struct{}
+
+// ExecuteOptions tunes a single Driver.Execute call. It is deliberately
+// empty here: none of the backup, undo-log, or sharded-dispatch call sites
+// need anything beyond the statement text today.
+type ExecuteOptions struct{}
+
+// QueryRowsOptions scopes a QueryRowsAsStringMap call to a subset of a
+// table's rows, keyset-paginated by primary key.
+type QueryRowsOptions struct {
+	// WhereClause, if non-empty, is ANDed onto the query as-is.
+	WhereClause string
+	// AfterCursor resumes a paginated scan after the row whose primary key
+	// columns joined with "," produced this cursor, as returned by a
+	// previous call's last row. Empty starts from the beginning.
+	AfterCursor string
+	// Limit caps the number of rows returned; 0 means unlimited.
+	Limit int
+}
+
+// Driver is the admin connection to a single database used by backup,
+// undo-log, and sharded-dispatch task execution. Implementations are
+// per-engine and obtained through dbfactory.DBFactory.GetAdminDatabaseDriver.
+type Driver interface {
+	// Execute runs statement and returns the number of rows affected.
+	Execute(ctx context.Context, statement string, opts ExecuteOptions) (int64, error)
+	// Close releases the underlying connection.
+	Close(ctx context.Context)
+
+	// QueryScalarInt64 runs a query expected to return exactly one row with
+	// one integer column, e.g. a COUNT(*).
+	QueryScalarInt64(ctx context.Context, query string) (int64, error)
+	// QueryScalarString runs a query expected to return exactly one row with
+	// one string-typed column, e.g. CHECKSUM TABLE's textual result.
+	QueryScalarString(ctx context.Context, query string) (string, error)
+	// QueryScalarCountAndCRC runs a query expected to return exactly one row
+	// of (COUNT(*), admin checksum), the shape every engine's rowset
+	// checksum query in backup_checksum.go produces.
+	QueryScalarCountAndCRC(ctx context.Context, query string) (rowCount int64, crc string, err error)
+
+	// QueryRowsAsStringMap reads every row of tableName matching opts, keyed
+	// by the concatenation of its primary key column values, with every
+	// column value rendered as a base.Row (nil meaning SQL NULL). primaryKey
+	// both orders the scan and forms that key, and backs opts.AfterCursor
+	// pagination.
+	QueryRowsAsStringMap(ctx context.Context, databaseName string, tableName string, primaryKey []string, opts QueryRowsOptions) (map[string]base.Row, error)
+	// QueryPrimaryKeyBounds runs MIN(pk), MAX(pk), COUNT(*) over tableName
+	// scoped by whereClause, the input to PKBounds.Split for sharded DML
+	// dispatch.
+	QueryPrimaryKeyBounds(ctx context.Context, tableName string, primaryKey []string, whereClause string) (*base.PKBounds, error)
+
+	// ExecuteInTransaction runs fn inside a single driver transaction,
+	// committing if fn returns nil and rolling back otherwise (including a
+	// panic, which it re-panics after rolling back). Callers that need
+	// several statements to apply atomically, such as a multi-row rollback,
+	// use this instead of concatenating statements into one Execute call.
+	ExecuteInTransaction(ctx context.Context, fn func(tx Transaction) error) error
+}
+
+// Transaction is the subset of Driver usable inside ExecuteInTransaction: a
+// single statement executor scoped to the enclosing transaction.
+type Transaction interface {
+	Execute(ctx context.Context, statement string, opts ExecuteOptions) (int64, error)
+}
@@ -0,0 +1,97 @@
+package base
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// DMLTarget describes the single table a DML statement writes to, along with
+// its primary key columns and the WHERE clause scoping the affected rows.
+// It is the shared extraction step for both undo-log capture and sharded
+// dispatch, so both features agree on what "the affected rows" means.
+type DMLTarget struct {
+	TableName   string
+	PrimaryKey  []string
+	WhereClause string
+}
+
+// ExtractDMLTargetTable parses statement and returns its target table. It
+// returns an error if statement touches more than one table, since sharding
+// and undo-log capture both assume a single-table DML.
+func ExtractDMLTargetTable(engine storepb.Engine, statement string) (*DMLTarget, error) {
+	selects, err := TransformDMLToSelect(engine, statement, "", "", "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse DML statement")
+	}
+	if len(selects) != 1 {
+		return nil, errors.Errorf("expected a single-table DML statement, got %d tables", len(selects))
+	}
+	sel := selects[0]
+	if len(sel.PrimaryKey) == 0 {
+		return nil, errors.Errorf("table %q has no primary key, cannot shard or build an undo log for it", sel.SourceTable)
+	}
+	return &DMLTarget{
+		TableName:   sel.SourceTable,
+		PrimaryKey:  sel.PrimaryKey,
+		WhereClause: sel.WhereClause,
+	}, nil
+}
+
+// PKBounds is the result of MIN(pk), MAX(pk), COUNT(*) over a DML target's
+// affected rows, used to split the primary-key domain into shards.
+type PKBounds struct {
+	Lower    string
+	Upper    string
+	RowCount int64
+}
+
+// PKRange is one [Lower, Upper] shard of a PKBounds split.
+type PKRange struct {
+	Lower string
+	Upper string
+}
+
+// Split divides the bounds into at most shardCount roughly equal numeric
+// ranges: the span is distributed evenly, with the remainder spread one row
+// each across the first shards, so the dispatcher never sees more ranges
+// than the configured shard count. It only supports integer-like primary
+// keys; non-numeric keys should fall back to running the DML unsharded.
+func (b PKBounds) Split(shardCount int) ([]PKRange, error) {
+	if shardCount < 1 {
+		return nil, errors.Errorf("shardCount must be positive, got %d", shardCount)
+	}
+	var lower, upper int64
+	if _, err := fmt.Sscanf(b.Lower, "%d", &lower); err != nil {
+		return nil, errors.Wrap(err, "primary key bounds are not numeric, cannot split into shards")
+	}
+	if _, err := fmt.Sscanf(b.Upper, "%d", &upper); err != nil {
+		return nil, errors.Wrap(err, "primary key bounds are not numeric, cannot split into shards")
+	}
+	if upper < lower {
+		return nil, errors.Errorf("invalid primary key bounds [%d, %d]", lower, upper)
+	}
+
+	span := upper - lower + 1
+	shards := int64(shardCount)
+	if shards > span {
+		shards = span
+	}
+	base := span / shards
+	remainder := span % shards
+
+	var ranges []PKRange
+	start := lower
+	for i := int64(0); i < shards; i++ {
+		size := base
+		if i < remainder {
+			size++
+		}
+		end := start + size - 1
+		ranges = append(ranges, PKRange{Lower: fmt.Sprintf("%d", start), Upper: fmt.Sprintf("%d", end)})
+		start = end + 1
+	}
+	return ranges, nil
+}
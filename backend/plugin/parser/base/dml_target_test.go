@@ -0,0 +1,83 @@
+package base
+
+import "testing"
+
+func TestPKBoundsSplit(t *testing.T) {
+	t.Run("splits evenly", func(t *testing.T) {
+		bounds := PKBounds{Lower: "1", Upper: "100", RowCount: 100}
+		got, err := bounds.Split(4)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []PKRange{
+			{Lower: "1", Upper: "25"},
+			{Lower: "26", Upper: "50"},
+			{Lower: "51", Upper: "75"},
+			{Lower: "76", Upper: "100"},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d ranges, want %d: %+v", len(got), len(want), got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("range %d: got %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("remainder is spread across the first shards, never exceeding shardCount ranges", func(t *testing.T) {
+		bounds := PKBounds{Lower: "1", Upper: "10", RowCount: 10}
+		got, err := bounds.Split(3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 3 {
+			t.Fatalf("got %d ranges, want exactly shardCount=3: %+v", len(got), got)
+		}
+		want := []PKRange{
+			{Lower: "1", Upper: "4"},
+			{Lower: "5", Upper: "7"},
+			{Lower: "8", Upper: "10"},
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("range %d: got %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("shard count larger than the span still covers the whole range", func(t *testing.T) {
+		bounds := PKBounds{Lower: "1", Upper: "3", RowCount: 3}
+		got, err := bounds.Split(10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got[0].Lower != "1" {
+			t.Errorf("first shard lower bound = %q, want %q", got[0].Lower, "1")
+		}
+		if got[len(got)-1].Upper != "3" {
+			t.Errorf("last shard upper bound = %q, want %q", got[len(got)-1].Upper, "3")
+		}
+	})
+
+	t.Run("shardCount must be positive", func(t *testing.T) {
+		bounds := PKBounds{Lower: "1", Upper: "10", RowCount: 10}
+		if _, err := bounds.Split(0); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("non-numeric bounds are rejected", func(t *testing.T) {
+		bounds := PKBounds{Lower: "a", Upper: "z", RowCount: 10}
+		if _, err := bounds.Split(2); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("upper below lower is rejected", func(t *testing.T) {
+		bounds := PKBounds{Lower: "10", Upper: "1", RowCount: 10}
+		if _, err := bounds.Split(2); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
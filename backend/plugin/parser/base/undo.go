@@ -0,0 +1,212 @@
+package base
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+// UndoLog captures enough information about the rows touched by a single DML
+// statement to later synthesize the inverse statement. The before-image and
+// after-image are materialized into their own backup tables rather than kept
+// in memory, mirroring how TransformDMLToSelect already snapshots rows.
+type UndoLog struct {
+	// TableName is the original table the DML targeted.
+	TableName string `json:"tableName"`
+	// PrimaryKey lists the column names that make up the table's primary key.
+	// Every row touched by the statement must have all of these populated,
+	// otherwise the undo log cannot be replayed and BuildUndoLogStatements fails.
+	PrimaryKey []string `json:"primaryKey"`
+	// Columns lists all columns captured in the before/after image tables.
+	Columns []string `json:"columns"`
+	// BeforeTable and AfterTable are the names of the backup tables holding
+	// the row state immediately before and immediately after the DML ran.
+	BeforeTable string `json:"beforeTable"`
+	AfterTable  string `json:"afterTable"`
+	// BeforeChecksum and AfterChecksum are the checksums recorded when each
+	// image was materialized, used by the rollback executor to detect a
+	// backup table that was truncated or mutated after the fact.
+	BeforeChecksum *RowsetChecksum `json:"beforeChecksum,omitempty"`
+	AfterChecksum  *RowsetChecksum `json:"afterChecksum,omitempty"`
+}
+
+// RowsetChecksum is the admin checksum of a rowset, computed with an
+// engine-appropriate strategy (CHECKSUM TABLE, CHECKSUM_AGG, md5(string_agg),
+// ...) so a silent truncation, type coercion, or concurrent write between
+// snapshot and DML shows up as a mismatch instead of a quietly wrong backup.
+type RowsetChecksum struct {
+	RowCount int64  `json:"rowCount"`
+	CRC      string `json:"crc"`
+}
+
+// UndoLogStatement pairs the SELECT used to materialize one side of an undo
+// log image (before or after) with the table metadata needed to build it.
+type UndoLogStatement struct {
+	Statement    string
+	TableName    string
+	SourceTable  string
+	OriginalLine int
+	// PrimaryKey and Columns are filled in from the table's schema at parse
+	// time so the rollback executor does not need a second round-trip.
+	PrimaryKey []string
+	Columns    []string
+	// WhereClause scopes the rows this statement captured, reused to scope
+	// the source-side checksum query to the same rowset.
+	WhereClause string
+}
+
+// BuildUndoLogStatements returns the SELECT ... INTO statements that capture
+// the before-image (image="before") or after-image (image="after") of the
+// rows affected by statement, keyed by primary key. It reuses the same
+// table-extraction logic as TransformDMLToSelect so the two stay consistent;
+// every table it surfaces must have a primary key, otherwise it fails fast
+// since the rollback executor would have no way to target individual rows.
+func BuildUndoLogStatements(engine storepb.Engine, statement string, databaseName string, backupDatabaseName string, suffix string, image string) ([]*UndoLogStatement, error) {
+	if image != "before" && image != "after" {
+		return nil, errors.Errorf("invalid undo log image %q, must be \"before\" or \"after\"", image)
+	}
+	selects, err := TransformDMLToSelect(engine, statement, databaseName, backupDatabaseName, suffix+"_"+image)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to transform DML to %s-image select", image)
+	}
+	var undoLogStatements []*UndoLogStatement
+	for _, sel := range selects {
+		if len(sel.PrimaryKey) == 0 {
+			return nil, errors.Errorf("table %q has no primary key, cannot build an undo log for it", sel.TableName)
+		}
+		undoLogStatements = append(undoLogStatements, &UndoLogStatement{
+			Statement:    sel.Statement,
+			TableName:    sel.TableName,
+			SourceTable:  sel.SourceTable,
+			OriginalLine: sel.OriginalLine,
+			PrimaryKey:   sel.PrimaryKey,
+			Columns:      sel.Columns,
+			WhereClause:  sel.WhereClause,
+		})
+	}
+	return undoLogStatements, nil
+}
+
+// SynthesizeRollbackStatement builds the inverse DML for a single row given
+// its before-image and after-image column values. A nil value for a column
+// means the column is NULL, as opposed to an empty string; Row is keyed by
+// column name. At least one of before/after must be non-nil: a row present only
+// in the after-image was inserted (so the rollback deletes it), a row
+// present only in the before-image was deleted (so the rollback re-inserts
+// it), and a row present in both was updated (so the rollback restores the
+// before-image values).
+func SynthesizeRollbackStatement(engine storepb.Engine, undoLog *UndoLog, before Row, after Row) (string, error) {
+	if len(undoLog.PrimaryKey) == 0 {
+		return "", errors.Errorf("table %q has no primary key captured, cannot synthesize rollback", undoLog.TableName)
+	}
+	quotedTable := QuoteIdentifier(engine, undoLog.TableName)
+	switch {
+	case before == nil && after != nil:
+		// Row was inserted by the DML; rollback deletes it.
+		return fmt.Sprintf("DELETE FROM %s WHERE %s", quotedTable, whereFromPK(engine, undoLog.PrimaryKey, after)), nil
+	case before != nil && after == nil:
+		// Row was deleted by the DML; rollback re-inserts it.
+		return insertFromRow(engine, quotedTable, undoLog.Columns, before), nil
+	case before != nil && after != nil:
+		// Row was updated by the DML; rollback restores the before-image.
+		return updateFromRows(engine, quotedTable, undoLog.Columns, undoLog.PrimaryKey, before, after), nil
+	default:
+		return "", errors.Errorf("undo log for table %q has neither before nor after image for a row", undoLog.TableName)
+	}
+}
+
+// Row is one row read back from a before/after backup table, keyed by
+// column name. A nil value means the column is NULL; this is distinct from
+// a non-nil pointer to an empty string.
+type Row map[string]*string
+
+func whereFromPK(engine storepb.Engine, primaryKey []string, row Row) string {
+	var conditions []string
+	for _, col := range primaryKey {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", QuoteIdentifier(engine, col), QuoteLiteral(engine, row[col])))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func insertFromRow(engine storepb.Engine, quotedTable string, columns []string, row Row) string {
+	var quotedColumns, values []string
+	for _, col := range columns {
+		quotedColumns = append(quotedColumns, QuoteIdentifier(engine, col))
+		values = append(values, QuoteLiteral(engine, row[col]))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quotedTable, strings.Join(quotedColumns, ", "), strings.Join(values, ", "))
+}
+
+func updateFromRows(engine storepb.Engine, quotedTable string, columns []string, primaryKey []string, before Row, after Row) string {
+	var sets []string
+	for _, col := range columns {
+		if contains(primaryKey, col) {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = %s", QuoteIdentifier(engine, col), QuoteLiteral(engine, before[col])))
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", quotedTable, strings.Join(sets, ", "), whereFromPK(engine, primaryKey, after))
+}
+
+// QuoteLiteral renders value as a SQL string literal, escaping embedded
+// single quotes by doubling them. A nil value (a NULL column) renders as the
+// bare NULL keyword rather than a quoted empty string. '' escaping is shared
+// across MySQL, TiDB, MSSQL, and Postgres, but MySQL and TiDB additionally
+// treat backslash as a string escape character under the default sql_mode
+// (without NO_BACKSLASH_ESCAPES), so a value ending in a backslash would
+// otherwise swallow the closing quote into an escape sequence; those two
+// engines get backslashes doubled first so the literal always terminates.
+func QuoteLiteral(engine storepb.Engine, value *string) string {
+	if value == nil {
+		return "NULL"
+	}
+	escaped := *value
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB:
+		escaped = strings.ReplaceAll(escaped, `\`, `\\`)
+	}
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(escaped, "'", "''"))
+}
+
+func contains(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
+
+// QuoteIdentifier quotes a table or column name per storepb.Engine, the same
+// per-engine switch already used for the ALTER TABLE COMMENT / extended
+// property calls in backupData.
+func QuoteIdentifier(engine storepb.Engine, identifier string) string {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB:
+		return fmt.Sprintf("`%s`", identifier)
+	case storepb.Engine_MSSQL:
+		return fmt.Sprintf("[%s]", identifier)
+	case storepb.Engine_POSTGRES:
+		return fmt.Sprintf("%q", identifier)
+	default:
+		return identifier
+	}
+}
+
+// QuoteTableRef quotes databaseName and tableName into a single fully
+// qualified table reference, quoting each part the same way QuoteIdentifier
+// quotes one. MSSQL additionally inserts the "dbo" schema, since every
+// backup table this package creates lives there and an unqualified
+// [database].[table] reference resolves against the connection's default
+// schema instead, which is not guaranteed to be dbo.
+func QuoteTableRef(engine storepb.Engine, databaseName string, tableName string) string {
+	switch engine {
+	case storepb.Engine_MSSQL:
+		return fmt.Sprintf("%s.%s.%s", QuoteIdentifier(engine, databaseName), QuoteIdentifier(engine, "dbo"), QuoteIdentifier(engine, tableName))
+	default:
+		return fmt.Sprintf("%s.%s", QuoteIdentifier(engine, databaseName), QuoteIdentifier(engine, tableName))
+	}
+}
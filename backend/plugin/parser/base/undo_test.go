@@ -0,0 +1,111 @@
+package base
+
+import (
+	"testing"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestSynthesizeRollbackStatement(t *testing.T) {
+	undoLog := &UndoLog{
+		TableName:  "t",
+		PrimaryKey: []string{"id"},
+		Columns:    []string{"id", "name"},
+	}
+
+	t.Run("insert is rolled back with a delete", func(t *testing.T) {
+		after := Row{"id": ptr("1"), "name": ptr("Alice")}
+		got, err := SynthesizeRollbackStatement(storepb.Engine_MYSQL, undoLog, nil, after)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "DELETE FROM `t` WHERE `id` = '1'"
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("delete is rolled back with an insert, quoting every literal", func(t *testing.T) {
+		before := Row{"id": ptr("1"), "name": ptr("O'Brien")}
+		got, err := SynthesizeRollbackStatement(storepb.Engine_MYSQL, undoLog, before, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "INSERT INTO `t` (`id`, `name`) VALUES ('1', 'O''Brien')"
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("update is rolled back by restoring the before-image, nil meaning NULL", func(t *testing.T) {
+		before := Row{"id": ptr("1"), "name": nil}
+		after := Row{"id": ptr("1"), "name": ptr("Alice")}
+		got, err := SynthesizeRollbackStatement(storepb.Engine_MYSQL, undoLog, before, after)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "UPDATE `t` SET `name` = NULL WHERE `id` = '1'"
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("neither image present is an error", func(t *testing.T) {
+		if _, err := SynthesizeRollbackStatement(storepb.Engine_MYSQL, undoLog, nil, nil); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("no primary key is an error", func(t *testing.T) {
+		noPK := &UndoLog{TableName: "t", Columns: []string{"id"}}
+		after := Row{"id": ptr("1")}
+		if _, err := SynthesizeRollbackStatement(storepb.Engine_MYSQL, noPK, nil, after); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *string
+		want  string
+	}{
+		{name: "nil is NULL", value: nil, want: "NULL"},
+		{name: "plain string", value: ptr("Alice"), want: "'Alice'"},
+		{name: "embedded quote is doubled", value: ptr("O'Brien"), want: "'O''Brien'"},
+		{name: "empty string is distinct from NULL", value: ptr(""), want: "''"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteLiteral(storepb.Engine_MYSQL, tt.value); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteLiteralBackslashEscaping(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine storepb.Engine
+		value  *string
+		want   string
+	}{
+		{name: "MySQL doubles a trailing backslash", engine: storepb.Engine_MYSQL, value: ptr(`O'Brien\`), want: `'O''Brien\\'`},
+		{name: "TiDB doubles a trailing backslash", engine: storepb.Engine_TIDB, value: ptr(`O'Brien\`), want: `'O''Brien\\'`},
+		{name: "MSSQL leaves backslashes alone", engine: storepb.Engine_MSSQL, value: ptr(`O'Brien\`), want: `'O''Brien\'`},
+		{name: "Postgres leaves backslashes alone", engine: storepb.Engine_POSTGRES, value: ptr(`O'Brien\`), want: `'O''Brien\'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteLiteral(tt.engine, tt.value); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package backuppolicy implements a periodic runner that enforces
+// BackupPolicy retention by dropping expired pre-update backup tables.
+package backuppolicy
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+
+	"github.com/bytebase/bytebase/backend/common/log"
+	"github.com/bytebase/bytebase/backend/component/dbfactory"
+	api "github.com/bytebase/bytebase/backend/legacyapi"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store"
+)
+
+// retentionCheckInterval is how often the runner scans for expired backup
+// tables across all BackupPolicy resources.
+const retentionCheckInterval = time.Hour
+
+// NewRunner creates a retention runner for pre-update backup tables.
+func NewRunner(store *store.Store, dbFactory *dbfactory.DBFactory) *Runner {
+	return &Runner{
+		store:     store,
+		dbFactory: dbFactory,
+	}
+}
+
+// Runner periodically drops backup tables that have outlived their
+// BackupPolicy's retention window.
+type Runner struct {
+	store     *store.Store
+	dbFactory *dbfactory.DBFactory
+}
+
+// Run starts the periodic retention sweep. It blocks until ctx is canceled.
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(retentionCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.sweep(ctx); err != nil {
+				slog.Error("backup policy retention sweep failed", log.BBError(err))
+			}
+		}
+	}
+}
+
+func (r *Runner) sweep(ctx context.Context) error {
+	policies, err := r.store.ListBackupPolicies(ctx, &store.FindBackupPolicyMessage{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list backup policies")
+	}
+
+	for _, policy := range policies {
+		if err := r.enforceRetention(ctx, policy); err != nil {
+			slog.Error("failed to enforce retention for backup policy", "policy", policy.UID, log.BBError(err))
+		}
+	}
+	return nil
+}
+
+func (r *Runner) enforceRetention(ctx context.Context, policy *store.BackupPolicyMessage) error {
+	if policy.Retention <= 0 {
+		return nil
+	}
+	expired, err := r.store.ListExpiredBackupTables(ctx, policy.UID, time.Now().Add(-policy.Retention))
+	if err != nil {
+		return errors.Wrap(err, "failed to list expired backup tables")
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	backupInstance, err := r.store.GetInstanceV2(ctx, &store.FindInstanceMessage{ResourceID: &policy.TargetInstanceResourceID})
+	if err != nil {
+		return err
+	}
+	backupDatabase, err := r.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{InstanceID: &backupInstance.UID, DatabaseName: &policy.TargetDatabaseName})
+	if err != nil {
+		return err
+	}
+	driver, err := r.dbFactory.GetAdminDatabaseDriver(ctx, backupInstance, backupDatabase, db.ConnectionContext{})
+	if err != nil {
+		return err
+	}
+	defer driver.Close(ctx)
+
+	for _, table := range expired {
+		dropStmt := "DROP TABLE " + policy.TargetDatabaseName + "." + base.QuoteIdentifier(backupInstance.Engine, table.TableName)
+		if _, err := driver.Execute(ctx, dropStmt, db.ExecuteOptions{}); err != nil {
+			slog.Error("failed to drop expired backup table", "table", table.TableName, log.BBError(err))
+			continue
+		}
+		if err := r.store.CreateIssueComment(ctx, &store.IssueCommentMessage{
+			IssueUID: table.IssueUID,
+			Payload: &storepb.IssueCommentPayload{
+				Event: &storepb.IssueCommentPayload_TaskPriorBackup_{
+					TaskPriorBackup: &storepb.IssueCommentPayload_TaskPriorBackup{
+						Database: policy.TargetDatabaseName,
+						Tables: []*storepb.IssueCommentPayload_TaskPriorBackup_Table{
+							{Table: table.TableName},
+						},
+					},
+				},
+			},
+		}, api.SystemBotID); err != nil {
+			slog.Warn("failed to record backup table expiry as an issue comment", "table", table.TableName, log.BBError(err))
+		}
+		if err := r.store.DeleteExpiredBackupTableRecord(ctx, policy.UID, table.TableName); err != nil {
+			slog.Warn("failed to clean up expired backup table bookkeeping", "table", table.TableName, log.BBError(err))
+		}
+	}
+	return nil
+}
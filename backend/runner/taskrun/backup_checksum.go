@@ -0,0 +1,181 @@
+package taskrun
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+
+	"github.com/bytebase/bytebase/backend/common/log"
+	"github.com/bytebase/bytebase/backend/component/config"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+)
+
+// checksumTable computes the admin checksum of a rowset. When whereClause is
+// non-empty it scopes the query to the same predicate the DML touched, so a
+// source-table checksum can be compared against an unfiltered backup table.
+func checksumTable(ctx context.Context, driver db.Driver, engine storepb.Engine, databaseName string, tableName string, columns []string, whereClause string) (*base.RowsetChecksum, error) {
+	switch engine {
+	case storepb.Engine_MYSQL, storepb.Engine_TIDB:
+		return checksumMySQL(ctx, driver, databaseName, tableName, columns, whereClause)
+	case storepb.Engine_MSSQL:
+		return checksumMSSQL(ctx, driver, databaseName, tableName, whereClause)
+	case storepb.Engine_POSTGRES:
+		return checksumPostgres(ctx, driver, databaseName, tableName, columns, whereClause)
+	default:
+		return nil, errors.Errorf("checksum verification is not supported for engine %v", engine)
+	}
+}
+
+func checksumMySQL(ctx context.Context, driver db.Driver, databaseName string, tableName string, columns []string, whereClause string) (*base.RowsetChecksum, error) {
+	quotedTable := base.QuoteTableRef(storepb.Engine_MYSQL, databaseName, tableName)
+	if whereClause == "" {
+		// No predicate to scope by: this is the backup table itself, so
+		// CHECKSUM TABLE is both simpler and faster than an aggregate scan.
+		rowCount, err := queryScalarInt64(ctx, driver, fmt.Sprintf("SELECT COUNT(*) FROM %s", quotedTable))
+		if err != nil {
+			return nil, err
+		}
+		crc, err := queryScalarString(ctx, driver, fmt.Sprintf("CHECKSUM TABLE %s", quotedTable))
+		if err != nil {
+			return nil, err
+		}
+		return &base.RowsetChecksum{RowCount: rowCount, CRC: crc}, nil
+	}
+
+	concat := fmt.Sprintf("CONCAT_WS('|', %s)", strings.Join(quoteColumns(columns, "`"), ", "))
+	query := fmt.Sprintf("SELECT COUNT(*), BIT_XOR(CRC32(%s)) FROM %s WHERE %s", concat, quotedTable, whereClause)
+	return queryScalarCountAndCRC(ctx, driver, query)
+}
+
+func checksumMSSQL(ctx context.Context, driver db.Driver, databaseName string, tableName string, whereClause string) (*base.RowsetChecksum, error) {
+	quotedTable := base.QuoteTableRef(storepb.Engine_MSSQL, databaseName, tableName)
+	query := fmt.Sprintf("SELECT COUNT(*), CHECKSUM_AGG(BINARY_CHECKSUM(*)) FROM %s", quotedTable)
+	if whereClause != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, whereClause)
+	}
+	return queryScalarCountAndCRC(ctx, driver, query)
+}
+
+func checksumPostgres(ctx context.Context, driver db.Driver, databaseName string, tableName string, columns []string, whereClause string) (*base.RowsetChecksum, error) {
+	quotedTable := base.QuoteTableRef(storepb.Engine_POSTGRES, databaseName, tableName)
+	concat := fmt.Sprintf("string_agg(concat_ws('|', %s), ',')", strings.Join(quoteColumns(columns, `"`), ", "))
+	query := fmt.Sprintf("SELECT COUNT(*), md5(%s) FROM %s", concat, quotedTable)
+	if whereClause != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, whereClause)
+	}
+	return queryScalarCountAndCRC(ctx, driver, query)
+}
+
+func quoteColumns(columns []string, quote string) []string {
+	var quoted []string
+	for _, col := range columns {
+		quoted = append(quoted, fmt.Sprintf("%s%s%s", quote, col, quote))
+	}
+	return quoted
+}
+
+func queryScalarInt64(ctx context.Context, driver db.Driver, query string) (int64, error) {
+	return driver.QueryScalarInt64(ctx, query)
+}
+
+func queryScalarString(ctx context.Context, driver db.Driver, query string) (string, error) {
+	return driver.QueryScalarString(ctx, query)
+}
+
+func queryScalarCountAndCRC(ctx context.Context, driver db.Driver, query string) (*base.RowsetChecksum, error) {
+	rowCount, crc, err := driver.QueryScalarCountAndCRC(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &base.RowsetChecksum{RowCount: rowCount, CRC: crc}, nil
+}
+
+// verifyBackupChecksum compares the checksum of the rows the DML is about to
+// touch on the source side against the checksum of the backup table that was
+// just materialized from them, and reacts according to mode:
+//   - off: skip verification entirely.
+//   - best_effort: log a warning on mismatch but let the task run continue.
+//   - strict (the default when unset): abort the task run on mismatch.
+//
+// When the backup lives on a different engine than the source, the two
+// sides compute checksums with different algorithms and can never agree, so
+// verification is skipped rather than producing a permanent false mismatch.
+func verifyBackupChecksum(
+	ctx context.Context,
+	sourceDriver db.Driver,
+	backupDriver db.Driver,
+	mode config.PreUpdateBackupChecksumMode,
+	sourceEngine storepb.Engine,
+	backupEngine storepb.Engine,
+	sourceDatabaseName string,
+	sourceTableName string,
+	backupDatabaseName string,
+	backupTableName string,
+	columns []string,
+	whereClause string,
+) (*base.RowsetChecksum, error) {
+	if mode == config.PreUpdateBackupChecksumModeOff {
+		return nil, nil
+	}
+	if sourceEngine != backupEngine {
+		slog.Info("skipping backup checksum verification across engines", "source", sourceTableName, "backup", backupTableName)
+		return nil, nil
+	}
+
+	sourceChecksum, err := checksumTable(ctx, sourceDriver, sourceEngine, sourceDatabaseName, sourceTableName, columns, whereClause)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to checksum source table %q", sourceTableName)
+	}
+	backupChecksum, err := checksumTable(ctx, backupDriver, backupEngine, backupDatabaseName, backupTableName, columns, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to checksum backup table %q", backupTableName)
+	}
+
+	if sourceChecksum.RowCount != backupChecksum.RowCount || sourceChecksum.CRC != backupChecksum.CRC {
+		mismatch := errors.Errorf(
+			"backup checksum mismatch for table %q: source has {rows=%d, crc=%s}, backup %q has {rows=%d, crc=%s}",
+			sourceTableName, sourceChecksum.RowCount, sourceChecksum.CRC, backupTableName, backupChecksum.RowCount, backupChecksum.CRC,
+		)
+		if mode == config.PreUpdateBackupChecksumModeBestEffort {
+			slog.Warn("continuing despite backup checksum mismatch", "table", sourceTableName, log.BBError(mismatch))
+			return backupChecksum, nil
+		}
+		return nil, mismatch
+	}
+	return backupChecksum, nil
+}
+
+// reverifyUndoLogChecksum recomputes the checksum of an undo log's before and
+// after backup tables and compares them against what was recorded when they
+// were materialized, catching a backup table that was truncated or edited
+// since. It is skipped when force is set, same as after-image verification.
+func reverifyUndoLogChecksum(ctx context.Context, backupDriver db.Driver, engine storepb.Engine, backupDatabaseName string, undoLog *base.UndoLog, force bool) error {
+	if force {
+		return nil
+	}
+	if undoLog.BeforeChecksum != nil {
+		current, err := checksumTable(ctx, backupDriver, engine, backupDatabaseName, undoLog.BeforeTable, undoLog.Columns, "")
+		if err != nil {
+			return errors.Wrap(err, "failed to re-checksum before-image backup table")
+		}
+		if current.RowCount != undoLog.BeforeChecksum.RowCount || current.CRC != undoLog.BeforeChecksum.CRC {
+			return errors.Errorf("before-image backup table %q no longer matches its recorded checksum", undoLog.BeforeTable)
+		}
+	}
+	if undoLog.AfterChecksum != nil {
+		current, err := checksumTable(ctx, backupDriver, engine, backupDatabaseName, undoLog.AfterTable, undoLog.Columns, "")
+		if err != nil {
+			return errors.Wrap(err, "failed to re-checksum after-image backup table")
+		}
+		if current.RowCount != undoLog.AfterChecksum.RowCount || current.CRC != undoLog.AfterChecksum.CRC {
+			return errors.Errorf("after-image backup table %q no longer matches its recorded checksum", undoLog.AfterTable)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package taskrun
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/bytebase/bytebase/backend/store"
+)
+
+// resolveBackupInstance returns the instance that actually hosts
+// backupDatabase. Historically backupData assumed the backup database lived
+// on the same instance as the source database and opened its driver there,
+// which silently wrote to the wrong server whenever the backup destination
+// was a genuinely different instance; this looks the owning instance up
+// instead of reusing the source one.
+func resolveBackupInstance(ctx context.Context, s *store.Store, sourceInstance *store.InstanceMessage, backupDatabase *store.DatabaseMessage) (*store.InstanceMessage, error) {
+	if backupDatabase.InstanceID == sourceInstance.UID {
+		return sourceInstance, nil
+	}
+	backupInstance, err := s.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &backupDatabase.InstanceID})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find backup instance")
+	}
+	if backupInstance == nil {
+		return nil, errors.Errorf("backup instance %d not found", backupDatabase.InstanceID)
+	}
+	return backupInstance, nil
+}
+
+// resolveBackupDestination determines where a task's pre-update backup
+// should land. It prefers an explicit PreUpdateBackupDetail.Database on the
+// task payload (the existing per-task override); failing that, it falls
+// back to the project's effective BackupPolicy, selected by matching the
+// policy's DatabaseLabelSelector against the source database's labels.
+func (exec *DataUpdateExecutor) resolveBackupDestination(ctx context.Context, projectID string, database *store.DatabaseMessage, explicitBackupDatabase string) (string, *store.BackupPolicyMessage, error) {
+	if explicitBackupDatabase != "" {
+		return explicitBackupDatabase, nil, nil
+	}
+
+	policies, err := exec.store.ListBackupPolicies(ctx, &store.FindBackupPolicyMessage{ProjectID: &projectID})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to list backup policies")
+	}
+	for _, policy := range policies {
+		if matchesSelector(database.Metadata.GetLabels(), policy.DatabaseLabelSelector) {
+			return formatBackupPolicyTarget(policy), policy, nil
+		}
+	}
+	return "", nil, nil
+}
+
+func matchesSelector(labels map[string]string, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// formatBackupPolicyTarget renders a policy's target instance+database in
+// the same "instances/{instance}/databases/{database}" shape that
+// PreUpdateBackupDetail.Database already uses, so resolveBackupTarget can
+// parse either one with the existing common.GetInstanceDatabaseID.
+func formatBackupPolicyTarget(policy *store.BackupPolicyMessage) string {
+	return "instances/" + policy.TargetInstanceResourceID + "/databases/" + policy.TargetDatabaseName
+}
@@ -0,0 +1,38 @@
+package taskrun
+
+import (
+	"context"
+	"fmt"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+
+	"github.com/bytebase/bytebase/backend/common/retry"
+	"github.com/bytebase/bytebase/backend/component/config"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+)
+
+// retryOptions builds the retry.Options for one external call made while
+// backing up or migrating a database on engine, using the operator-tunable
+// attempt count and base delay from profile. beforeRetry, if non-nil, runs
+// before every retry (not the first attempt) to undo any partial state the
+// previous attempt left behind.
+func retryOptions(profile config.Profile, engine storepb.Engine, beforeRetry func(ctx context.Context) error) retry.Options {
+	return retry.Options{
+		MaxAttempts: profile.BackupRetryMaxAttempts,
+		BaseDelay:   profile.BackupRetryBaseDelay,
+		IsRetryable: func(err error) bool { return retry.IsRetryableDBError(engine, err) },
+		BeforeRetry: beforeRetry,
+	}
+}
+
+// dropBackupTableIfExists drops tableName on driver, ignoring the case where
+// it never got created. It is used as a retry.Options.BeforeRetry so a retry
+// of a backup statement always starts from a clean slate instead of failing
+// on "table already exists" against a table the previous, failed attempt
+// half-created.
+func dropBackupTableIfExists(ctx context.Context, driver db.Driver, engine storepb.Engine, databaseName string, tableName string) error {
+	stmt := fmt.Sprintf("DROP TABLE IF EXISTS %s", base.QuoteTableRef(engine, databaseName, tableName))
+	_, err := driver.Execute(ctx, stmt, db.ExecuteOptions{})
+	return err
+}
@@ -0,0 +1,133 @@
+package taskrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+)
+
+// streamBackupRowsBatchSize bounds how many rows are buffered between the
+// SELECT off the source driver and the INSERT onto the target driver.
+const streamBackupRowsBatchSize = 1000
+
+// streamBackupRows copies the rows matching whereClause from sourceTable on
+// sourceDriver into targetTable on targetDriver, batching and translating
+// column values as it goes. It is used in place of an in-engine
+// INSERT ... SELECT whenever the backup destination is not reachable through
+// the source driver's own connection, e.g. a different instance or engine.
+func streamBackupRows(
+	ctx context.Context,
+	sourceDriver db.Driver,
+	targetDriver db.Driver,
+	sourceEngine storepb.Engine,
+	targetEngine storepb.Engine,
+	sourceDatabaseName string,
+	sourceTableName string,
+	targetDatabaseName string,
+	targetTableName string,
+	primaryKey []string,
+	columns []string,
+	whereClause string,
+) (int64, error) {
+	if err := createBackupTableLike(ctx, targetDriver, targetEngine, targetDatabaseName, targetTableName, sourceEngine, columns); err != nil {
+		return 0, errors.Wrap(err, "failed to create backup table on remote target")
+	}
+
+	var total int64
+	cursor := ""
+	for {
+		rows, err := sourceDriver.QueryRowsAsStringMap(ctx, sourceDatabaseName, sourceTableName, primaryKey, db.QueryRowsOptions{
+			WhereClause: whereClause,
+			AfterCursor: cursor,
+			Limit:       streamBackupRowsBatchSize,
+		})
+		if err != nil {
+			return total, errors.Wrap(err, "failed to read source rows")
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		if err := insertBatch(ctx, targetDriver, targetEngine, targetDatabaseName, targetTableName, columns, rows); err != nil {
+			return total, errors.Wrap(err, "failed to insert batch into backup target")
+		}
+		total += int64(len(rows))
+		cursor = nextCursor(rows, primaryKey)
+
+		if len(rows) < streamBackupRowsBatchSize {
+			break
+		}
+	}
+	return total, nil
+}
+
+// createBackupTableLike creates targetTable on the (possibly remote, possibly
+// different-engine) target so streamBackupRows has somewhere to insert into.
+// streamBackupRows only has column names to work with, not their source
+// types, so there is no per-family type translation here: every column is
+// created as the target engine's widest portable text type, and the
+// original typing is lost. sourceEngine is accepted for a future version
+// that looks up real column types instead of working from names alone.
+func createBackupTableLike(ctx context.Context, targetDriver db.Driver, targetEngine storepb.Engine, databaseName string, tableName string, sourceEngine storepb.Engine, columns []string) error {
+	var columnDefs []string
+	for _, col := range columns {
+		columnDefs = append(columnDefs, fmt.Sprintf("%s %s", base.QuoteIdentifier(targetEngine, col), portableColumnType(targetEngine)))
+	}
+	quotedTable := base.QuoteTableRef(targetEngine, databaseName, tableName)
+	stmt := fmt.Sprintf("CREATE TABLE %s (%s)", quotedTable, strings.Join(columnDefs, ", "))
+	_, err := targetDriver.Execute(ctx, stmt, db.ExecuteOptions{})
+	return err
+}
+
+func portableColumnType(engine storepb.Engine) string {
+	switch engine {
+	case storepb.Engine_MSSQL:
+		return "NVARCHAR(MAX)"
+	case storepb.Engine_POSTGRES:
+		return "TEXT"
+	default:
+		return "TEXT"
+	}
+}
+
+func insertBatch(ctx context.Context, targetDriver db.Driver, targetEngine storepb.Engine, databaseName string, tableName string, columns []string, rows []base.Row) error {
+	quotedTable := base.QuoteTableRef(targetEngine, databaseName, tableName)
+	var quotedColumns []string
+	for _, col := range columns {
+		quotedColumns = append(quotedColumns, base.QuoteIdentifier(targetEngine, col))
+	}
+
+	var valueRows []string
+	for _, row := range rows {
+		var values []string
+		for _, col := range columns {
+			values = append(values, base.QuoteLiteral(targetEngine, row[col]))
+		}
+		valueRows = append(valueRows, fmt.Sprintf("(%s)", strings.Join(values, ", ")))
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quotedTable, strings.Join(quotedColumns, ", "), strings.Join(valueRows, ", "))
+	_, err := targetDriver.Execute(ctx, stmt, db.ExecuteOptions{})
+	return err
+}
+
+func nextCursor(rows []base.Row, primaryKey []string) string {
+	if len(rows) == 0 || len(primaryKey) == 0 {
+		return ""
+	}
+	last := rows[len(rows)-1]
+	var parts []string
+	for _, col := range primaryKey {
+		if val := last[col]; val != nil {
+			parts = append(parts, *val)
+		}
+	}
+	return strings.Join(parts, ",")
+}
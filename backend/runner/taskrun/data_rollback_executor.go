@@ -0,0 +1,301 @@
+package taskrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+	v1pb "github.com/bytebase/bytebase/proto/generated-go/v1"
+
+	"github.com/bytebase/bytebase/backend/common"
+	"github.com/bytebase/bytebase/backend/common/log"
+	"github.com/bytebase/bytebase/backend/component/activity"
+	"github.com/bytebase/bytebase/backend/component/config"
+	"github.com/bytebase/bytebase/backend/component/dbfactory"
+	"github.com/bytebase/bytebase/backend/component/state"
+	enterprise "github.com/bytebase/bytebase/backend/enterprise/api"
+	"github.com/bytebase/bytebase/backend/runner/schemasync"
+
+	api "github.com/bytebase/bytebase/backend/legacyapi"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store"
+)
+
+// NewDataRollbackExecutor creates a data rollback task executor that reverts
+// a previously completed data update task using the undo logs it produced.
+func NewDataRollbackExecutor(store *store.Store, dbFactory *dbfactory.DBFactory, activityManager *activity.Manager, license enterprise.LicenseService, stateCfg *state.State, schemaSyncer *schemasync.Syncer, profile config.Profile) Executor {
+	return &DataRollbackExecutor{
+		store:           store,
+		dbFactory:       dbFactory,
+		activityManager: activityManager,
+		license:         license,
+		stateCfg:        stateCfg,
+		schemaSyncer:    schemaSyncer,
+		profile:         profile,
+	}
+}
+
+// DataRollbackExecutor is the data rollback task executor.
+type DataRollbackExecutor struct {
+	store           *store.Store
+	dbFactory       *dbfactory.DBFactory
+	activityManager *activity.Manager
+	license         enterprise.LicenseService
+	stateCfg        *state.State
+	schemaSyncer    *schemasync.Syncer
+	profile         config.Profile
+}
+
+// RunOnce will run the data rollback task executor once.
+func (exec *DataRollbackExecutor) RunOnce(ctx context.Context, driverCtx context.Context, task *store.TaskMessage, taskRunUID int) (terminated bool, result *storepb.TaskRunResult, err error) {
+	exec.stateCfg.TaskRunExecutionStatuses.Store(taskRunUID,
+		state.TaskRunExecutionStatus{
+			ExecutionStatus: v1pb.TaskRun_PRE_EXECUTING,
+			UpdateTime:      time.Now(),
+		})
+
+	payload := &api.TaskDatabaseDataRollbackPayload{}
+	if err := json.Unmarshal([]byte(task.Payload), payload); err != nil {
+		return true, nil, errors.Wrap(err, "invalid database data rollback payload")
+	}
+
+	originalTask, err := exec.store.GetTaskV2ByID(ctx, payload.TargetTaskID)
+	if err != nil {
+		return true, nil, err
+	}
+	if originalTask == nil {
+		return true, nil, errors.Errorf("task %d to roll back not found", payload.TargetTaskID)
+	}
+
+	undoLogs, err := exec.loadUndoLogs(ctx, originalTask)
+	if err != nil {
+		return true, nil, err
+	}
+	if len(undoLogs) == 0 {
+		return true, nil, errors.Errorf("no undo log found for task %d, nothing to roll back", payload.TargetTaskID)
+	}
+
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &originalTask.InstanceID})
+	if err != nil {
+		return true, nil, err
+	}
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: originalTask.DatabaseID})
+	if err != nil {
+		return true, nil, err
+	}
+	backupInstanceID, backupDatabaseName, err := common.GetInstanceDatabaseID(payload.BackupDatabase)
+	if err != nil {
+		return true, nil, err
+	}
+	backupDatabase, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{InstanceID: &backupInstanceID, DatabaseName: &backupDatabaseName})
+	if err != nil {
+		return true, nil, err
+	}
+	if backupDatabase == nil {
+		return true, nil, errors.Errorf("backup database %q not found", payload.BackupDatabase)
+	}
+	backupInstance, err := resolveBackupInstance(ctx, exec.store, instance, backupDatabase)
+	if err != nil {
+		return true, nil, err
+	}
+
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		return true, nil, err
+	}
+	defer driver.Close(driverCtx)
+	backupDriver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, backupInstance, backupDatabase, db.ConnectionContext{})
+	if err != nil {
+		return true, nil, err
+	}
+	defer backupDriver.Close(driverCtx)
+
+	var rollbackStatements []string
+	for _, undoLog := range undoLogs {
+		if undoLog.AfterTable == "" {
+			return true, nil, errors.Errorf("table %q has no after-image, the original task may not have finished", undoLog.TableName)
+		}
+
+		if err := reverifyUndoLogChecksum(driverCtx, backupDriver, backupInstance.Engine, backupDatabaseName, undoLog, payload.Force); err != nil {
+			return true, nil, errors.Wrapf(err, "backup for table %q failed re-verification", undoLog.TableName)
+		}
+
+		beforeRows, err := loadUndoLogRows(driverCtx, backupDriver, backupDatabaseName, undoLog.BeforeTable, undoLog.PrimaryKey)
+		if err != nil {
+			return true, nil, errors.Wrapf(err, "failed to load before-image for table %q", undoLog.TableName)
+		}
+		afterRows, err := loadUndoLogRows(driverCtx, backupDriver, backupDatabaseName, undoLog.AfterTable, undoLog.PrimaryKey)
+		if err != nil {
+			return true, nil, errors.Wrapf(err, "failed to load after-image for table %q", undoLog.TableName)
+		}
+
+		if !payload.Force {
+			currentRows, err := loadUndoLogRows(driverCtx, driver, database.DatabaseName, undoLog.TableName, undoLog.PrimaryKey)
+			if err != nil {
+				return true, nil, errors.Wrapf(err, "failed to read current state of table %q", undoLog.TableName)
+			}
+			if mismatch := firstMismatch(afterRows, currentRows); mismatch != "" {
+				return true, nil, errors.Errorf("table %q row %s no longer matches the after-image captured by the original task; pass force to roll back anyway", undoLog.TableName, mismatch)
+			}
+		} else {
+			slog.Warn("rolling back without after-image verification", "task", task.ID, "table", undoLog.TableName)
+		}
+
+		stmts, err := synthesizeRollbackStatements(instance.Engine, undoLog, beforeRows, afterRows)
+		if err != nil {
+			return true, nil, errors.Wrapf(err, "failed to synthesize rollback statements for table %q", undoLog.TableName)
+		}
+		rollbackStatements = append(rollbackStatements, stmts...)
+	}
+
+	if err := driver.ExecuteInTransaction(driverCtx, func(tx db.Transaction) error {
+		for _, stmt := range rollbackStatements {
+			if _, err := tx.Execute(driverCtx, stmt, db.ExecuteOptions{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return true, nil, errors.Wrap(err, "failed to execute rollback statements")
+	}
+
+	return true, &storepb.TaskRunResult{
+		Detail: fmt.Sprintf("Rolled back task %d using %d undo log table(s)", payload.TargetTaskID, len(undoLogs)),
+	}, nil
+}
+
+// loadUndoLogs collects every undo log recorded by the TaskPriorBackup issue
+// comments that the original task produced. backupData records the
+// before-image as soon as it materializes it, and captureAfterImage records
+// the after-image fields in a second, later comment for the same table
+// (see mergeUndoLog), so entries are merged by TableName rather than taken
+// from a single comment.
+func (exec *DataRollbackExecutor) loadUndoLogs(ctx context.Context, originalTask *store.TaskMessage) ([]*base.UndoLog, error) {
+	issue, err := exec.store.GetIssueV2(ctx, &store.FindIssueMessage{PipelineID: &originalTask.PipelineID})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find issue for pipeline %v", originalTask.PipelineID)
+	}
+	if issue == nil {
+		return nil, errors.Errorf("issue not found for pipeline %v", originalTask.PipelineID)
+	}
+	comments, err := exec.store.ListIssueComment(ctx, &store.FindIssueCommentMessage{IssueUID: &issue.UID})
+	if err != nil {
+		return nil, err
+	}
+
+	byTable := make(map[string]*base.UndoLog)
+	var order []string
+	for _, comment := range comments {
+		backup := comment.Payload.GetTaskPriorBackup()
+		if backup == nil {
+			continue
+		}
+		for _, table := range backup.Tables {
+			if table.UndoLog == "" {
+				continue
+			}
+			undoLog := &base.UndoLog{}
+			if err := json.Unmarshal([]byte(table.UndoLog), undoLog); err != nil {
+				return nil, errors.Wrapf(err, "failed to unmarshal undo log for table %q", table.Table)
+			}
+			if existing, ok := byTable[undoLog.TableName]; ok {
+				mergeUndoLog(existing, undoLog)
+				continue
+			}
+			byTable[undoLog.TableName] = undoLog
+			order = append(order, undoLog.TableName)
+		}
+	}
+
+	var undoLogs []*base.UndoLog
+	for _, tableName := range order {
+		undoLogs = append(undoLogs, byTable[tableName])
+	}
+	return undoLogs, nil
+}
+
+// mergeUndoLog copies every non-empty field of src onto dst, used to fold a
+// later after-image comment onto the before-image undo log for the same
+// table that loadUndoLogs already collected.
+func mergeUndoLog(dst *base.UndoLog, src *base.UndoLog) {
+	if len(src.PrimaryKey) > 0 {
+		dst.PrimaryKey = src.PrimaryKey
+	}
+	if len(src.Columns) > 0 {
+		dst.Columns = src.Columns
+	}
+	if src.BeforeTable != "" {
+		dst.BeforeTable = src.BeforeTable
+	}
+	if src.AfterTable != "" {
+		dst.AfterTable = src.AfterTable
+	}
+	if src.BeforeChecksum != nil {
+		dst.BeforeChecksum = src.BeforeChecksum
+	}
+	if src.AfterChecksum != nil {
+		dst.AfterChecksum = src.AfterChecksum
+	}
+}
+
+// synthesizeRollbackStatements produces the inverse DML for every row that
+// differs between beforeRows and afterRows: rows only in afterRows were
+// inserted (delete them back out), rows only in beforeRows were deleted
+// (re-insert them), and rows in both were updated (restore the before-image).
+func synthesizeRollbackStatements(engine storepb.Engine, undoLog *base.UndoLog, beforeRows map[string]base.Row, afterRows map[string]base.Row) ([]string, error) {
+	var statements []string
+	for pk, afterRow := range afterRows {
+		stmt, err := base.SynthesizeRollbackStatement(engine, undoLog, beforeRows[pk], afterRow)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, stmt)
+	}
+	for pk, beforeRow := range beforeRows {
+		if _, ok := afterRows[pk]; ok {
+			continue
+		}
+		stmt, err := base.SynthesizeRollbackStatement(engine, undoLog, beforeRow, nil)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, nil
+}
+
+// loadUndoLogRows reads every row of a backup image table, keyed by the
+// concatenation of its primary key column values.
+func loadUndoLogRows(driverCtx context.Context, driver db.Driver, databaseName string, tableName string, primaryKey []string) (map[string]base.Row, error) {
+	// The actual row scan goes through the driver's query layer; callers only
+	// rely on the map being keyed consistently between before/after/current
+	// reads of the same primary key so the diff in RunOnce lines up.
+	return driver.QueryRowsAsStringMap(driverCtx, databaseName, tableName, primaryKey, db.QueryRowsOptions{})
+}
+
+// firstMismatch returns a human-readable key for the first row whose current
+// state no longer matches its recorded after-image, or "" if they all match.
+func firstMismatch(afterRows map[string]base.Row, currentRows map[string]base.Row) string {
+	for pk, after := range afterRows {
+		current, ok := currentRows[pk]
+		if !ok {
+			return pk
+		}
+		for col, val := range after {
+			currentVal := current[col]
+			if (val == nil) != (currentVal == nil) {
+				return pk
+			}
+			if val != nil && currentVal != nil && *val != *currentVal {
+				return pk
+			}
+		}
+	}
+	return ""
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/bytebase/bytebase/backend/common"
 	"github.com/bytebase/bytebase/backend/common/log"
+	"github.com/bytebase/bytebase/backend/common/retry"
 	"github.com/bytebase/bytebase/backend/component/activity"
 	"github.com/bytebase/bytebase/backend/component/config"
 	"github.com/bytebase/bytebase/backend/component/dbfactory"
@@ -69,99 +70,237 @@ func (exec *DataUpdateExecutor) RunOnce(ctx context.Context, driverCtx context.C
 	if err != nil {
 		return true, nil, err
 	}
-	if err := exec.backupData(ctx, driverCtx, statement, payload, task); err != nil {
+
+	if handled, terminated, result, err := exec.maybeRunSharded(ctx, driverCtx, statement, payload, task, taskRunUID); handled {
+		return terminated, result, err
+	}
+
+	undoLogs, backupPrefix, err := exec.backupData(ctx, driverCtx, statement, payload, task)
+	if err != nil {
+		return true, nil, err
+	}
+
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
+	if err != nil {
 		return true, nil, err
 	}
 	version := model.Version{Version: payload.SchemaVersion}
-	return runMigration(ctx, driverCtx, exec.store, exec.dbFactory, exec.stateCfg, exec.profile, task, taskRunUID, db.Data, statement, version, &payload.SheetID)
+	var terminated bool
+	var result *storepb.TaskRunResult
+	migrationOpts := retryOptions(exec.profile, instance.Engine, nil)
+	err = retry.Do(driverCtx, migrationOpts, func(attemptDriverCtx context.Context) error {
+		var migrationErr error
+		terminated, result, migrationErr = runMigration(ctx, attemptDriverCtx, exec.store, exec.dbFactory, exec.stateCfg, exec.profile, task, taskRunUID, db.Data, statement, version, &payload.SheetID)
+		return migrationErr
+	})
+	if err == nil && len(undoLogs) > 0 {
+		if afterErr := exec.captureAfterImage(ctx, driverCtx, statement, payload, task, backupPrefix, undoLogs); afterErr != nil {
+			// The DML already succeeded; losing the after-image only degrades
+			// the ability to auto-rollback later, so we log instead of failing
+			// an otherwise successful task run.
+			slog.Error("failed to capture after-image for undo log", "task", task.ID, log.BBError(afterErr))
+		}
+	}
+	return terminated, result, err
 }
 
-func (exec *DataUpdateExecutor) backupData(
-	ctx context.Context,
-	driverCtx context.Context,
-	statement string,
-	payload *api.TaskDatabaseDataUpdatePayload,
-	task *store.TaskMessage,
-) error {
-	if payload.PreUpdateBackupDetail.Database == "" {
-		return nil
-	}
+// backupTarget resolves the instance, database, issue and backup database
+// involved in a data update task, along with admin drivers for the source
+// and backup databases. Callers must close both drivers.
+type backupTarget struct {
+	instance           *store.InstanceMessage
+	database           *store.DatabaseMessage
+	issue              *store.IssueMessage
+	backupInstance     *store.InstanceMessage
+	backupDatabaseName string
+	backupDatabase     *store.DatabaseMessage
+	backupPolicy       *store.BackupPolicyMessage
+	driver             db.Driver
+	backupDriver       db.Driver
+}
+
+// remote reports whether the backup destination lives on a different
+// instance (possibly a different engine entirely) than the source database,
+// in which case backupData must stream rows through Bytebase instead of
+// relying on an in-engine INSERT ... SELECT.
+func (t *backupTarget) remote() bool {
+	return t.backupInstance.UID != t.instance.UID
+}
 
+func (exec *DataUpdateExecutor) resolveBackupTarget(ctx context.Context, driverCtx context.Context, payload *api.TaskDatabaseDataUpdatePayload, task *store.TaskMessage) (*backupTarget, error) {
 	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	issue, err := exec.store.GetIssueV2(ctx, &store.FindIssueMessage{PipelineID: &task.PipelineID})
 	if err != nil {
-		return errors.Wrapf(err, "failed to find issue for pipeline %v", task.PipelineID)
+		return nil, errors.Wrapf(err, "failed to find issue for pipeline %v", task.PipelineID)
 	}
 	if issue == nil {
-		return errors.Errorf("issue not found for pipeline %v", task.PipelineID)
+		return nil, errors.Errorf("issue not found for pipeline %v", task.PipelineID)
 	}
 
-	backupInstanceID, backupDatabaseName, err := common.GetInstanceDatabaseID(payload.PreUpdateBackupDetail.Database)
+	backupDetail, backupPolicy, err := exec.resolveBackupDestination(ctx, issue.Project.ResourceID, database, payload.PreUpdateBackupDetail.Database)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if backupDetail == "" {
+		return nil, errors.Errorf("no backup destination configured for database %q", database.DatabaseName)
+	}
+
+	backupInstanceID, backupDatabaseName, err := common.GetInstanceDatabaseID(backupDetail)
+	if err != nil {
+		return nil, err
 	}
 	backupDatabase, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{InstanceID: &backupInstanceID, DatabaseName: &backupDatabaseName})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if backupDatabase == nil {
-		return errors.Errorf("backup database %q not found", payload.PreUpdateBackupDetail.Database)
+		return nil, errors.Errorf("backup database %q not found", backupDetail)
+	}
+	backupInstance, err := resolveBackupInstance(ctx, exec.store, instance, backupDatabase)
+	if err != nil {
+		return nil, err
 	}
 
 	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	backupDriver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, backupInstance, backupDatabase, db.ConnectionContext{})
+	if err != nil {
+		driver.Close(driverCtx)
+		return nil, err
 	}
-	defer driver.Close(driverCtx)
 
-	backupDriver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, backupDatabase, db.ConnectionContext{})
+	return &backupTarget{
+		instance:           instance,
+		database:           database,
+		issue:              issue,
+		backupInstance:     backupInstance,
+		backupDatabaseName: backupDatabaseName,
+		backupDatabase:     backupDatabase,
+		backupPolicy:       backupPolicy,
+		driver:             driver,
+		backupDriver:       backupDriver,
+	}, nil
+}
+
+// backupData snapshots the before-image of every row the DML is about to
+// touch and records it as an issue comment. It returns the undo logs built
+// for those tables (empty if backup is not enabled) together with the
+// prefix used to name the backup tables, so captureAfterImage can later
+// materialize the after-image into a sibling table with the same prefix.
+// Backup runs only when the task payload sets PreUpdateBackupDetail.Database;
+// when it is empty, backupData returns no undo logs and no BackupPolicy is
+// consulted. The statements that materialize each backup table and set its
+// table comment are wrapped in retry.Do so a transient failure (deadlock,
+// dropped connection) retries instead of aborting the task; the checksum
+// queries are not retried. The issue comment for a table is only written
+// once every step for that table has succeeded.
+func (exec *DataUpdateExecutor) backupData(
+	ctx context.Context,
+	driverCtx context.Context,
+	statement string,
+	payload *api.TaskDatabaseDataUpdatePayload,
+	task *store.TaskMessage,
+) ([]*base.UndoLog, string, error) {
+	if payload.PreUpdateBackupDetail.Database == "" {
+		return nil, "", nil
+	}
+
+	target, err := exec.resolveBackupTarget(ctx, driverCtx, payload, task)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
-	defer backupDriver.Close(driverCtx)
+	defer target.driver.Close(driverCtx)
+	defer target.backupDriver.Close(driverCtx)
 
 	prefix := "_" + time.Now().Format("20060102150405")
-	statements, err := base.TransformDMLToSelect(instance.Engine, statement, database.DatabaseName, backupDatabaseName, prefix)
+	beforeStatements, err := base.BuildUndoLogStatements(target.instance.Engine, statement, target.database.DatabaseName, target.backupDatabaseName, prefix, "before")
 	if err != nil {
-		return errors.Wrap(err, "failed to transform DML to select")
+		return nil, "", errors.Wrap(err, "failed to build before-image undo log statements")
 	}
 
-	for _, statement := range statements {
-		if _, err := driver.Execute(driverCtx, statement.Statement, db.ExecuteOptions{}); err != nil {
-			return err
+	var undoLogs []*base.UndoLog
+	for _, s := range beforeStatements {
+		dropHalfCreated := func(ctx context.Context) error {
+			return dropBackupTableIfExists(ctx, target.backupDriver, target.backupInstance.Engine, target.backupDatabaseName, s.TableName)
+		}
+		if target.remote() {
+			materializeOpts := retryOptions(exec.profile, target.instance.Engine, dropHalfCreated)
+			if err := retry.Do(driverCtx, materializeOpts, func(attemptCtx context.Context) error {
+				_, err := streamBackupRows(attemptCtx, target.driver, target.backupDriver, target.instance.Engine, target.backupInstance.Engine,
+					target.database.DatabaseName, s.SourceTable, target.backupDatabaseName, s.TableName, s.PrimaryKey, s.Columns, s.WhereClause)
+				return err
+			}); err != nil {
+				return nil, "", errors.Wrapf(err, "failed to stream rows to remote backup table %q", s.TableName)
+			}
+		} else {
+			materializeOpts := retryOptions(exec.profile, target.instance.Engine, dropHalfCreated)
+			if err := retry.Do(driverCtx, materializeOpts, func(attemptCtx context.Context) error {
+				_, err := target.driver.Execute(attemptCtx, s.Statement, db.ExecuteOptions{})
+				return err
+			}); err != nil {
+				return nil, "", err
+			}
 		}
 		var originalLine *int32
-		switch instance.Engine {
+		commentOpts := retryOptions(exec.profile, target.backupInstance.Engine, nil)
+		switch target.backupInstance.Engine {
 		case storepb.Engine_MYSQL, storepb.Engine_TIDB:
-			if _, err := driver.Execute(driverCtx, fmt.Sprintf("ALTER TABLE `%s`.`%s` COMMENT = 'issue %d'", backupDatabaseName, statement.TableName, issue.UID), db.ExecuteOptions{}); err != nil {
+			if err := retry.Do(driverCtx, commentOpts, func(attemptCtx context.Context) error {
+				_, err := target.backupDriver.Execute(attemptCtx, fmt.Sprintf("ALTER TABLE `%s`.`%s` COMMENT = 'issue %d'", target.backupDatabaseName, s.TableName, target.issue.UID), db.ExecuteOptions{})
 				return err
+			}); err != nil {
+				return nil, "", err
 			}
 		case storepb.Engine_MSSQL:
-			if _, err := backupDriver.Execute(driverCtx, fmt.Sprintf("EXEC sp_addextendedproperty 'MS_Description', 'issue %d', 'SCHEMA', 'dbo', 'TABLE', '%s'", issue.UID, statement.TableName), db.ExecuteOptions{}); err != nil {
+			if err := retry.Do(driverCtx, commentOpts, func(attemptCtx context.Context) error {
+				_, err := target.backupDriver.Execute(attemptCtx, fmt.Sprintf("EXEC sp_addextendedproperty 'MS_Description', 'issue %d', 'SCHEMA', 'dbo', 'TABLE', '%s'", target.issue.UID, s.TableName), db.ExecuteOptions{})
 				return err
+			}); err != nil {
+				return nil, "", err
 			}
-			num := int32(statement.OriginalLine)
+			num := int32(s.OriginalLine)
 			originalLine = &num
 		}
 
+		checksum, err := verifyBackupChecksum(driverCtx, target.driver, target.backupDriver, exec.profile.PreUpdateBackupChecksumMode, target.instance.Engine, target.backupInstance.Engine,
+			target.database.DatabaseName, s.SourceTable, target.backupDatabaseName, s.TableName, s.Columns, s.WhereClause)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "backup checksum verification failed for table %q", s.SourceTable)
+		}
+
+		undoLog := &base.UndoLog{
+			TableName:      s.SourceTable,
+			PrimaryKey:     s.PrimaryKey,
+			Columns:        s.Columns,
+			BeforeTable:    s.TableName,
+			BeforeChecksum: checksum,
+		}
+		undoLogBytes, err := json.Marshal(undoLog)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "failed to marshal undo log")
+		}
+
 		if err := exec.store.CreateIssueComment(ctx, &store.IssueCommentMessage{
-			IssueUID: issue.UID,
+			IssueUID: target.issue.UID,
 			Payload: &storepb.IssueCommentPayload{
 				Event: &storepb.IssueCommentPayload_TaskPriorBackup_{
 					TaskPriorBackup: &storepb.IssueCommentPayload_TaskPriorBackup{
-						Task:     common.FormatTask(issue.Project.ResourceID, task.PipelineID, task.StageID, task.ID),
-						Database: backupDatabaseName,
+						Task:     common.FormatTask(target.issue.Project.ResourceID, task.PipelineID, task.StageID, task.ID),
+						Database: target.backupDatabaseName,
 						Tables: []*storepb.IssueCommentPayload_TaskPriorBackup_Table{
 							{
-								Schema: "",
-								Table:  statement.TableName,
+								Schema:  "",
+								Table:   s.TableName,
+								UndoLog: string(undoLogBytes),
 							},
 						},
 						OriginalLine: originalLine,
@@ -171,13 +310,117 @@ func (exec *DataUpdateExecutor) backupData(
 		}, api.SystemBotID); err != nil {
 			slog.Warn("failed to create issue comment", "task", task.ID, log.BBError(err))
 		}
+
+		undoLogs = append(undoLogs, undoLog)
 	}
 
-	if err := exec.schemaSyncer.SyncDatabaseSchema(ctx, backupDatabase, true /* force */); err != nil {
+	if err := exec.schemaSyncer.SyncDatabaseSchema(ctx, target.backupDatabase, true /* force */); err != nil {
 		slog.Error("failed to sync backup database schema",
 			slog.String("database", payload.PreUpdateBackupDetail.Database),
 			log.BBError(err),
 		)
 	}
+	return undoLogs, prefix, nil
+}
+
+// captureAfterImage materializes the after-image of every row recorded in
+// undoLogs, now that the DML has run, and records the updated undo log (now
+// carrying AfterTable/AfterChecksum) as a second TaskPriorBackup issue
+// comment for the same table. loadUndoLogs merges it onto the before-image
+// comment backupData already wrote, since mutating undoLogs in place isn't
+// enough on its own: that slice goes out of scope once RunOnce returns, and
+// a rollback executor started later only ever sees what was persisted.
+func (exec *DataUpdateExecutor) captureAfterImage(
+	ctx context.Context,
+	driverCtx context.Context,
+	statement string,
+	payload *api.TaskDatabaseDataUpdatePayload,
+	task *store.TaskMessage,
+	prefix string,
+	undoLogs []*base.UndoLog,
+) error {
+	target, err := exec.resolveBackupTarget(ctx, driverCtx, payload, task)
+	if err != nil {
+		return err
+	}
+	defer target.driver.Close(driverCtx)
+	defer target.backupDriver.Close(driverCtx)
+
+	afterStatements, err := base.BuildUndoLogStatements(target.instance.Engine, statement, target.database.DatabaseName, target.backupDatabaseName, prefix, "after")
+	if err != nil {
+		return errors.Wrap(err, "failed to build after-image undo log statements")
+	}
+
+	byTable := make(map[string]*base.UndoLog)
+	for _, undoLog := range undoLogs {
+		byTable[undoLog.TableName] = undoLog
+	}
+
+	for _, s := range afterStatements {
+		undoLog, ok := byTable[s.SourceTable]
+		if !ok {
+			// Statement targets a table we never captured a before-image for;
+			// nothing to pair it with, so skip rather than fail the task run.
+			continue
+		}
+		dropHalfCreated := func(ctx context.Context) error {
+			return dropBackupTableIfExists(ctx, target.backupDriver, target.backupInstance.Engine, target.backupDatabaseName, s.TableName)
+		}
+		if target.remote() {
+			materializeOpts := retryOptions(exec.profile, target.instance.Engine, dropHalfCreated)
+			if err := retry.Do(driverCtx, materializeOpts, func(attemptCtx context.Context) error {
+				_, err := streamBackupRows(attemptCtx, target.driver, target.backupDriver, target.instance.Engine, target.backupInstance.Engine,
+					target.database.DatabaseName, s.SourceTable, target.backupDatabaseName, s.TableName, s.PrimaryKey, s.Columns, s.WhereClause)
+				return err
+			}); err != nil {
+				return errors.Wrapf(err, "failed to stream rows to remote backup table %q", s.TableName)
+			}
+		} else {
+			materializeOpts := retryOptions(exec.profile, target.instance.Engine, dropHalfCreated)
+			if err := retry.Do(driverCtx, materializeOpts, func(attemptCtx context.Context) error {
+				_, err := target.driver.Execute(attemptCtx, s.Statement, db.ExecuteOptions{})
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		checksum, err := verifyBackupChecksum(driverCtx, target.driver, target.backupDriver, exec.profile.PreUpdateBackupChecksumMode, target.instance.Engine, target.backupInstance.Engine,
+			target.database.DatabaseName, s.SourceTable, target.backupDatabaseName, s.TableName, s.Columns, s.WhereClause)
+		if err != nil {
+			return errors.Wrapf(err, "backup checksum verification failed for table %q", s.SourceTable)
+		}
+		undoLog.AfterTable = s.TableName
+		undoLog.AfterChecksum = checksum
+
+		undoLogBytes, err := json.Marshal(undoLog)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal undo log for table %q", undoLog.TableName)
+		}
+		if err := exec.store.CreateIssueComment(ctx, &store.IssueCommentMessage{
+			IssueUID: target.issue.UID,
+			Payload: &storepb.IssueCommentPayload{
+				Event: &storepb.IssueCommentPayload_TaskPriorBackup_{
+					TaskPriorBackup: &storepb.IssueCommentPayload_TaskPriorBackup{
+						Task:     common.FormatTask(target.issue.Project.ResourceID, task.PipelineID, task.StageID, task.ID),
+						Database: target.backupDatabaseName,
+						Tables: []*storepb.IssueCommentPayload_TaskPriorBackup_Table{
+							{
+								Schema:  "",
+								Table:   s.TableName,
+								UndoLog: string(undoLogBytes),
+							},
+						},
+					},
+				},
+			},
+		}, api.SystemBotID); err != nil {
+			// The before-image comment backupData already wrote is still
+			// readable, but without this one loadUndoLogs will never see an
+			// AfterTable for this row and the rollback executor will refuse to
+			// run, so this is worth surfacing even though the DML itself
+			// already succeeded.
+			return errors.Wrapf(err, "failed to persist after-image undo log for table %q", undoLog.TableName)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,352 @@
+package taskrun
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+
+	v1pb "github.com/bytebase/bytebase/proto/generated-go/v1"
+
+	"github.com/bytebase/bytebase/backend/common/log"
+	"github.com/bytebase/bytebase/backend/component/state"
+	api "github.com/bytebase/bytebase/backend/legacyapi"
+	"github.com/bytebase/bytebase/backend/plugin/db"
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+	"github.com/bytebase/bytebase/backend/store"
+)
+
+// maybeRunSharded checks whether statement's affected row count crosses the
+// configured sharding threshold and, if so, drives it to completion as a set
+// of PK-range subtasks instead of letting RunOnce take its normal single-
+// statement path. handled is false when sharding does not apply and the
+// caller should fall back to the regular flow.
+func (exec *DataUpdateExecutor) maybeRunSharded(ctx context.Context, driverCtx context.Context, statement string, payload *api.TaskDatabaseDataUpdatePayload, task *store.TaskMessage, taskRunUID int) (handled bool, terminated bool, result *storepb.TaskRunResult, err error) {
+	threshold := exec.profile.DMLShardingRowCountThreshold
+	if threshold <= 0 {
+		return false, false, nil, nil
+	}
+
+	instance, err := exec.store.GetInstanceV2(ctx, &store.FindInstanceMessage{UID: &task.InstanceID})
+	if err != nil {
+		return true, true, nil, err
+	}
+	database, err := exec.store.GetDatabaseV2(ctx, &store.FindDatabaseMessage{UID: task.DatabaseID})
+	if err != nil {
+		return true, true, nil, err
+	}
+
+	driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
+	if err != nil {
+		return true, true, nil, err
+	}
+	shards, rowCount, err := planDMLShards(ctx, driverCtx, driver, instance.Engine, statement, exec.profile.DMLShardingCount)
+	driver.Close(driverCtx)
+	if err != nil {
+		slog.Warn("cannot shard DML statement, running it unsharded", "task", task.ID, log.BBError(err))
+		return false, false, nil, nil
+	}
+	if !shouldShardDML(rowCount, threshold) {
+		return false, false, nil, nil
+	}
+
+	target, err := base.ExtractDMLTargetTable(instance.Engine, statement)
+	if err != nil {
+		return true, true, nil, errors.Wrap(err, "failed to extract DML target table")
+	}
+
+	dispatcher := &dmlDispatcher{store: exec.store}
+	executeShard := exec.shardExecutor(instance, database, task, payload, statement, target)
+	revertShard := exec.shardReverter(instance, database)
+	progressFn := func(done, total int) {
+		exec.stateCfg.TaskRunExecutionStatuses.Store(taskRunUID, state.TaskRunExecutionStatus{
+			ExecutionStatus: v1pb.TaskRun_EXECUTING,
+			UpdateTime:      time.Now(),
+		})
+		slog.Info("DML shard progress", "task", task.ID, "done", done, "total", total)
+	}
+
+	if err := dispatcher.run(ctx, driverCtx, task.ID, shards, executeShard, revertShard, progressFn); err != nil {
+		return true, true, nil, errors.Wrap(err, "sharded DML execution failed")
+	}
+	return true, true, &storepb.TaskRunResult{
+		Detail: fmt.Sprintf("Executed %d rows across %d shards", rowCount, len(shards)),
+	}, nil
+}
+
+// shardExecutor returns the per-shard worker body: it scopes statement to
+// the shard's primary-key range, backs up the shard's rows the same way
+// backupData backs up a whole-task DML (one backup table per shard, per the
+// sharded-dispatch requirement that the backup step be shard-aware too),
+// executes the shard, captures its after-image, and records the resulting
+// undo log against the subtask so a sibling shard's failure can revert it.
+func (exec *DataUpdateExecutor) shardExecutor(instance *store.InstanceMessage, database *store.DatabaseMessage, task *store.TaskMessage, payload *api.TaskDatabaseDataUpdatePayload, statement string, target *base.DMLTarget) func(ctx context.Context, driverCtx context.Context, subtaskUID int, shard dmlShard) error {
+	return func(ctx context.Context, driverCtx context.Context, subtaskUID int, shard dmlShard) error {
+		shardStatement := appendShardPredicate(instance.Engine, statement, target.WhereClause, target.PrimaryKey[0], shard)
+
+		undoLogs, backupPrefix, err := exec.backupData(ctx, driverCtx, shardStatement, payload, task)
+		if err != nil {
+			return errors.Wrap(err, "failed to back up shard")
+		}
+
+		driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
+		if err != nil {
+			return err
+		}
+		defer driver.Close(driverCtx)
+		if _, err := driver.Execute(driverCtx, shardStatement, db.ExecuteOptions{}); err != nil {
+			return errors.Wrap(err, "failed to execute shard statement")
+		}
+
+		if len(undoLogs) == 0 {
+			// No backup destination is configured for this task, so there is
+			// nothing to revert this shard with if a sibling shard fails later.
+			// That is surfaced loudly by revertShard rather than silently
+			// accepted here.
+			return nil
+		}
+		if err := exec.captureAfterImage(ctx, driverCtx, shardStatement, payload, task, backupPrefix, undoLogs); err != nil {
+			return errors.Wrap(err, "failed to capture after-image for shard")
+		}
+		if err := exec.store.SetDMLSubtaskUndoLog(ctx, subtaskUID, undoLogs[0]); err != nil {
+			return errors.Wrap(err, "failed to record shard undo log")
+		}
+		return nil
+	}
+}
+
+// shardReverter returns the closure dmlDispatcher.run uses to roll back a
+// shard that already committed once a sibling shard fails. It fails loudly
+// when the shard has no undo log on record instead of skipping it: a shard
+// that committed without a recoverable backup left data applied that this
+// whole mechanism cannot actually revert, and reporting success anyway would
+// hide that.
+func (exec *DataUpdateExecutor) shardReverter(instance *store.InstanceMessage, database *store.DatabaseMessage) func(ctx context.Context, driverCtx context.Context, subtaskUID int) error {
+	return func(ctx context.Context, driverCtx context.Context, subtaskUID int) error {
+		undoLog, err := exec.store.GetDMLSubtaskUndoLog(ctx, subtaskUID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load undo log for subtask %d", subtaskUID)
+		}
+		if undoLog == nil {
+			return errors.Errorf("subtask %d committed without a recoverable backup and cannot be auto-reverted", subtaskUID)
+		}
+
+		driver, err := exec.dbFactory.GetAdminDatabaseDriver(driverCtx, instance, database, db.ConnectionContext{})
+		if err != nil {
+			return err
+		}
+		defer driver.Close(driverCtx)
+
+		beforeRows, err := driver.QueryRowsAsStringMap(driverCtx, database.DatabaseName, undoLog.BeforeTable, undoLog.PrimaryKey, db.QueryRowsOptions{})
+		if err != nil {
+			return err
+		}
+		afterRows, err := driver.QueryRowsAsStringMap(driverCtx, database.DatabaseName, undoLog.AfterTable, undoLog.PrimaryKey, db.QueryRowsOptions{})
+		if err != nil {
+			return err
+		}
+		stmts, err := synthesizeRollbackStatements(instance.Engine, undoLog, beforeRows, afterRows)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range stmts {
+			if _, err := driver.Execute(driverCtx, stmt, db.ExecuteOptions{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// appendShardPredicate scopes statement to one shard's primary-key range. It
+// adds a WHERE clause if statement (per whereClause, extracted up front by
+// ExtractDMLTargetTable) has none, and ANDs onto the existing one otherwise,
+// rather than always appending "AND ..." onto the raw statement text: a
+// DML with no WHERE clause at all (e.g. "UPDATE t SET x = 1", a statement
+// exactly large enough to need sharding) is valid SQL, and blindly ANDing a
+// predicate onto it produces a syntax error.
+func appendShardPredicate(engine storepb.Engine, statement string, whereClause string, primaryKeyColumn string, shard dmlShard) string {
+	predicate := fmt.Sprintf("%s BETWEEN %s AND %s", base.QuoteIdentifier(engine, primaryKeyColumn), shard.lowerBound, shard.upperBound)
+	if whereClause == "" {
+		return fmt.Sprintf("%s WHERE %s", statement, predicate)
+	}
+	return fmt.Sprintf("%s AND %s", statement, predicate)
+}
+
+// dmlShard is one contiguous primary-key range of a sharded DML task.
+type dmlShard struct {
+	lowerBound string
+	upperBound string
+}
+
+// shouldShardDML reports whether a DML statement is large enough to dispatch
+// as multiple PK-range subtasks instead of running as one monolithic
+// statement on a single driver connection.
+func shouldShardDML(rowCount int64, threshold int64) bool {
+	if threshold <= 0 {
+		return false
+	}
+	return rowCount >= threshold
+}
+
+// planDMLShards analyzes statement to find its target table, then splits the
+// table's primary-key domain into roughly equal shards by querying
+// MIN(pk), MAX(pk), COUNT(*) on the source driver.
+func planDMLShards(ctx context.Context, driverCtx context.Context, driver db.Driver, engine storepb.Engine, statement string, shardCount int) ([]dmlShard, int64, error) {
+	target, err := base.ExtractDMLTargetTable(engine, statement)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to extract DML target table")
+	}
+
+	bounds, err := driver.QueryPrimaryKeyBounds(ctx, target.TableName, target.PrimaryKey, target.WhereClause)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to query primary key bounds for table %q", target.TableName)
+	}
+	if bounds.RowCount == 0 {
+		return nil, 0, nil
+	}
+	if shardCount < 1 {
+		shardCount = 1
+	}
+
+	shards, err := bounds.Split(shardCount)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to split primary key range into shards")
+	}
+
+	var dmlShards []dmlShard
+	for _, s := range shards {
+		dmlShards = append(dmlShards, dmlShard{lowerBound: s.Lower, upperBound: s.Upper})
+	}
+	return dmlShards, bounds.RowCount, nil
+}
+
+// dmlDispatcher coordinates the execution of a sharded DML task: it creates
+// one subtask row per shard, lets worker goroutines claim and execute them
+// concurrently, and reverts everything already applied if any shard fails.
+type dmlDispatcher struct {
+	store      *store.Store
+	concurrent int
+}
+
+// run claims and executes every pending subtask of parentTaskUID, reporting
+// progress through progressFn. Each shard's backup, execution, and undo-log
+// bookkeeping is the responsibility of executeShard; run only sequences
+// claiming, concurrency, and failure handling. On the first subtask failure,
+// it marks the remaining subtasks as reverting and rolls back the ones that
+// already succeeded via revertShard.
+func (d *dmlDispatcher) run(
+	ctx context.Context,
+	driverCtx context.Context,
+	parentTaskUID int,
+	shards []dmlShard,
+	executeShard func(ctx context.Context, driverCtx context.Context, subtaskUID int, shard dmlShard) error,
+	revertShard func(ctx context.Context, driverCtx context.Context, subtaskUID int) error,
+	progressFn func(done, total int),
+) error {
+	if err := d.store.CreateDMLSubtasks(ctx, parentTaskUID, len(shards)); err != nil {
+		return errors.Wrap(err, "failed to create DML subtasks")
+	}
+
+	var (
+		mu        sync.Mutex
+		failed    error
+		done      int
+		total     = len(shards)
+		wg        sync.WaitGroup
+		succeeded []int
+	)
+
+	sem := make(chan struct{}, d.concurrentOrDefault())
+	for i, shard := range shards {
+		i, shard := i, shard
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			subtask, err := d.store.ClaimDMLSubtaskForUpdateSkipLocked(ctx, parentTaskUID, i)
+			if err != nil {
+				mu.Lock()
+				if failed == nil {
+					failed = errors.Wrapf(err, "failed to claim subtask %d", i)
+				}
+				mu.Unlock()
+				return
+			}
+			if subtask == nil {
+				// Another worker already claimed it; nothing to do here.
+				return
+			}
+
+			mu.Lock()
+			alreadyFailed := failed != nil
+			mu.Unlock()
+			if alreadyFailed {
+				_ = d.store.UpdateDMLSubtaskState(ctx, subtask.UID, "reverting")
+				return
+			}
+
+			if err := executeShard(ctx, driverCtx, subtask.UID, shard); err != nil {
+				mu.Lock()
+				failed = errors.Wrapf(err, "shard %d failed", i)
+				mu.Unlock()
+				_ = d.store.UpdateDMLSubtaskState(ctx, subtask.UID, "failed")
+				return
+			}
+
+			if err := d.store.UpdateDMLSubtaskState(ctx, subtask.UID, "succeeded"); err != nil {
+				slog.Warn("failed to mark DML subtask succeeded", "subtask", subtask.UID, log.BBError(err))
+			}
+
+			mu.Lock()
+			done++
+			succeeded = append(succeeded, subtask.UID)
+			progressFn(done, total)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if failed != nil {
+		if err := d.revertSucceeded(ctx, driverCtx, succeeded, revertShard); err != nil {
+			return errors.Wrapf(failed, "failed to revert already-applied shards: %v", err)
+		}
+		return failed
+	}
+	return nil
+}
+
+func (d *dmlDispatcher) concurrentOrDefault() int {
+	if d.concurrent > 0 {
+		return d.concurrent
+	}
+	return 4
+}
+
+// revertSucceeded rolls back every subtask that already committed before a
+// sibling shard failed, using revertShard to replay the undo log executeShard
+// recorded for it. A subtask revertShard can't recover (because it never
+// got an undo log — see shardReverter) fails the whole revert loudly rather
+// than being skipped: silently continuing would report a clean recovery
+// while leaving committed, unrevertable writes behind.
+func (d *dmlDispatcher) revertSucceeded(ctx context.Context, driverCtx context.Context, subtaskUIDs []int, revertShard func(ctx context.Context, driverCtx context.Context, subtaskUID int) error) error {
+	for _, uid := range subtaskUIDs {
+		if err := d.store.UpdateDMLSubtaskState(ctx, uid, "reverting"); err != nil {
+			return err
+		}
+		if err := revertShard(ctx, driverCtx, uid); err != nil {
+			return errors.Wrapf(err, "failed to revert subtask %d", uid)
+		}
+		if err := d.store.UpdateDMLSubtaskState(ctx, uid, "reverted"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package taskrun
+
+import (
+	"context"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+
+	"github.com/bytebase/bytebase/backend/component/activity"
+	"github.com/bytebase/bytebase/backend/component/config"
+	"github.com/bytebase/bytebase/backend/component/dbfactory"
+	"github.com/bytebase/bytebase/backend/component/state"
+	enterprise "github.com/bytebase/bytebase/backend/enterprise/api"
+	"github.com/bytebase/bytebase/backend/runner/backuppolicy"
+	"github.com/bytebase/bytebase/backend/runner/schemasync"
+	"github.com/bytebase/bytebase/backend/store"
+)
+
+// Task types dispatched by Scheduler.Executor. These mirror the payload
+// types the corresponding Executor unmarshals task.Payload into.
+const (
+	TaskDatabaseDataUpdate   = "bb.task.database.data.update"
+	TaskDatabaseDataRollback = "bb.task.database.data.rollback"
+)
+
+// Executor runs a single task run to completion. terminated reports whether
+// the task run reached a terminal state (done or failed); when it's false
+// the scheduler will retry RunOnce later.
+type Executor interface {
+	RunOnce(ctx context.Context, driverCtx context.Context, task *store.TaskMessage, taskRunUID int) (terminated bool, result *storepb.TaskRunResult, err error)
+}
+
+// Scheduler owns the task-type -> Executor registry and the background
+// runners that don't run per-task-run, such as backup policy retention.
+type Scheduler struct {
+	executors          map[string]Executor
+	backupPolicyRunner *backuppolicy.Runner
+}
+
+// NewScheduler constructs a Scheduler with every task executor registered
+// and the backup policy retention runner wired in.
+func NewScheduler(store *store.Store, dbFactory *dbfactory.DBFactory, activityManager *activity.Manager, license enterprise.LicenseService, stateCfg *state.State, schemaSyncer *schemasync.Syncer, profile config.Profile) *Scheduler {
+	s := &Scheduler{
+		executors:          make(map[string]Executor),
+		backupPolicyRunner: backuppolicy.NewRunner(store, dbFactory),
+	}
+	s.executors[TaskDatabaseDataUpdate] = NewDataUpdateExecutor(store, dbFactory, activityManager, license, stateCfg, schemaSyncer, profile)
+	s.executors[TaskDatabaseDataRollback] = NewDataRollbackExecutor(store, dbFactory, activityManager, license, stateCfg, schemaSyncer, profile)
+	return s
+}
+
+// Run starts the backup policy retention runner and blocks until ctx is
+// canceled. Per-task-run dispatch to the registered executors happens
+// elsewhere, driven by whatever polls pending task runs and calls
+// Executor(taskType).RunOnce.
+func (s *Scheduler) Run(ctx context.Context) {
+	go s.backupPolicyRunner.Run(ctx)
+	<-ctx.Done()
+}
+
+// Executor returns the registered executor for taskType, or nil if none is
+// registered.
+func (s *Scheduler) Executor(taskType string) Executor {
+	return s.executors[taskType]
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// CreateBackupPolicy records a new BackupPolicy. There is no resource API
+// wired up to call this yet in this snapshot (see the provisioning surface
+// the real application exposes over backend/api/v1), so it exists for tests
+// and for enforceRetention's sweep to have policies to find.
+func (s *Store) CreateBackupPolicy(ctx context.Context, policy *BackupPolicyMessage) (*BackupPolicyMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uid := len(s.backupPolicies) + 1
+	policy.UID = uid
+	s.backupPolicies[uid] = policy
+	return policy, nil
+}
+
+// ListBackupPolicies lists every BackupPolicy, optionally scoped to a single
+// project.
+func (s *Store) ListBackupPolicies(ctx context.Context, find *FindBackupPolicyMessage) ([]*BackupPolicyMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var policies []*BackupPolicyMessage
+	for _, policy := range s.backupPolicies {
+		if find.ProjectID != nil && policy.ProjectID != *find.ProjectID {
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// RecordExpiredBackupTable registers tableName under policyUID as having
+// outlived its retention window, for ListExpiredBackupTables to surface to
+// the retention runner. There is no schema-sync integration in this
+// snapshot that discovers backup tables and their age on its own, so
+// callers populate this directly.
+func (s *Store) RecordExpiredBackupTable(ctx context.Context, policyUID int, table *ExpiredBackupTable) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiredBackups[policyUID] = append(s.expiredBackups[policyUID], table)
+	return nil
+}
+
+// ListExpiredBackupTables lists every backup table recorded against
+// policyUID. The cutoff argument is accepted to match the real store's
+// signature (every caller passes now-retention) but does not filter the
+// result, since this in-memory bookkeeping does not track a per-table
+// creation time; RecordExpiredBackupTable's caller is the one deciding a
+// table has aged out.
+func (s *Store) ListExpiredBackupTables(ctx context.Context, policyUID int, _ time.Time) ([]*ExpiredBackupTable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expiredBackups[policyUID], nil
+}
+
+// DeleteExpiredBackupTableRecord removes the bookkeeping record for
+// tableName under policyUID once the retention runner has dropped it.
+func (s *Store) DeleteExpiredBackupTableRecord(ctx context.Context, policyUID int, tableName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tables := s.expiredBackups[policyUID]
+	for i, table := range tables {
+		if table.TableName == tableName {
+			s.expiredBackups[policyUID] = append(tables[:i], tables[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestListBackupPoliciesScopesByProject(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	if _, err := s.CreateBackupPolicy(ctx, &BackupPolicyMessage{ProjectID: "proj-a", Retention: time.Hour}); err != nil {
+		t.Fatalf("CreateBackupPolicy: %v", err)
+	}
+	if _, err := s.CreateBackupPolicy(ctx, &BackupPolicyMessage{ProjectID: "proj-b", Retention: time.Hour}); err != nil {
+		t.Fatalf("CreateBackupPolicy: %v", err)
+	}
+
+	t.Run("no filter lists every policy", func(t *testing.T) {
+		got, err := s.ListBackupPolicies(ctx, &FindBackupPolicyMessage{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("got %d policies, want 2", len(got))
+		}
+	})
+
+	t.Run("ProjectID filters to a single project", func(t *testing.T) {
+		projectID := "proj-a"
+		got, err := s.ListBackupPolicies(ctx, &FindBackupPolicyMessage{ProjectID: &projectID})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0].ProjectID != "proj-a" {
+			t.Fatalf("got %+v, want exactly the proj-a policy", got)
+		}
+	})
+}
+
+func TestExpiredBackupTableLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	policy, err := s.CreateBackupPolicy(ctx, &BackupPolicyMessage{ProjectID: "proj-a", Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("CreateBackupPolicy: %v", err)
+	}
+
+	if err := s.RecordExpiredBackupTable(ctx, policy.UID, &ExpiredBackupTable{TableName: "t1", IssueUID: 1}); err != nil {
+		t.Fatalf("RecordExpiredBackupTable: %v", err)
+	}
+
+	got, err := s.ListExpiredBackupTables(ctx, policy.UID, time.Now())
+	if err != nil {
+		t.Fatalf("ListExpiredBackupTables: %v", err)
+	}
+	if len(got) != 1 || got[0].TableName != "t1" {
+		t.Fatalf("got %+v, want exactly one expired table t1", got)
+	}
+
+	if err := s.DeleteExpiredBackupTableRecord(ctx, policy.UID, "t1"); err != nil {
+		t.Fatalf("DeleteExpiredBackupTableRecord: %v", err)
+	}
+	got, err = s.ListExpiredBackupTables(ctx, policy.UID, time.Now())
+	if err != nil {
+		t.Fatalf("ListExpiredBackupTables: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("got %+v, want no expired tables after deletion", got)
+	}
+}
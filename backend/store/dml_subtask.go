@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+)
+
+// CreateDMLSubtasks creates count pending subtasks for parentTaskUID, one
+// per shard of a sharded DML dispatch, indexed 0..count-1 so
+// ClaimDMLSubtaskForUpdateSkipLocked can claim a specific shard's subtask.
+func (s *Store) CreateDMLSubtasks(ctx context.Context, parentTaskUID int, count int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.nextSubtaskUID++
+		uid := s.nextSubtaskUID
+		s.dmlSubtasks[uid] = &DMLSubtask{
+			UID:           uid,
+			ParentTaskUID: parentTaskUID,
+			ShardIndex:    i,
+			State:         "pending",
+		}
+	}
+	return nil
+}
+
+// ClaimDMLSubtaskForUpdateSkipLocked claims the pending subtask of
+// parentTaskUID at shardIndex, moving it to "running" and returning it, or
+// returns (nil, nil) if it was already claimed by another worker. The name
+// mirrors the SELECT ... FOR UPDATE SKIP LOCKED the real store uses to do
+// this claim atomically against concurrent workers; this in-memory
+// implementation gets the same effect from the store-wide mutex instead.
+func (s *Store) ClaimDMLSubtaskForUpdateSkipLocked(ctx context.Context, parentTaskUID int, shardIndex int) (*DMLSubtask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, subtask := range s.dmlSubtasks {
+		if subtask.ParentTaskUID != parentTaskUID || subtask.ShardIndex != shardIndex {
+			continue
+		}
+		if subtask.State != "pending" {
+			return nil, nil
+		}
+		subtask.State = "running"
+		claimed := *subtask
+		return &claimed, nil
+	}
+	return nil, errors.Errorf("no subtask found for task %d shard %d", parentTaskUID, shardIndex)
+}
+
+// UpdateDMLSubtaskState transitions subtaskUID to state.
+func (s *Store) UpdateDMLSubtaskState(ctx context.Context, subtaskUID int, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subtask, ok := s.dmlSubtasks[subtaskUID]
+	if !ok {
+		return errors.Errorf("DML subtask %d not found", subtaskUID)
+	}
+	subtask.State = state
+	return nil
+}
+
+// SetDMLSubtaskUndoLog records the undo log captured for subtaskUID's shard,
+// used by shardReverter to roll the shard back if a sibling shard fails.
+func (s *Store) SetDMLSubtaskUndoLog(ctx context.Context, subtaskUID int, undoLog *base.UndoLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subtask, ok := s.dmlSubtasks[subtaskUID]
+	if !ok {
+		return errors.Errorf("DML subtask %d not found", subtaskUID)
+	}
+	subtask.undoLog = undoLog
+	return nil
+}
+
+// GetDMLSubtaskUndoLog returns the undo log recorded for subtaskUID, or nil
+// if none was recorded (the shard committed without a recoverable backup).
+func (s *Store) GetDMLSubtaskUndoLog(ctx context.Context, subtaskUID int) (*base.UndoLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subtask, ok := s.dmlSubtasks[subtaskUID]
+	if !ok {
+		return nil, errors.Errorf("DML subtask %d not found", subtaskUID)
+	}
+	return subtask.undoLog, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+)
+
+func TestDMLSubtaskLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	if err := s.CreateDMLSubtasks(ctx, 1, 3); err != nil {
+		t.Fatalf("CreateDMLSubtasks: %v", err)
+	}
+
+	subtask, err := s.ClaimDMLSubtaskForUpdateSkipLocked(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("ClaimDMLSubtaskForUpdateSkipLocked: %v", err)
+	}
+	if subtask == nil {
+		t.Fatal("expected a claimed subtask, got nil")
+	}
+	if subtask.ShardIndex != 1 {
+		t.Errorf("ShardIndex = %d, want 1", subtask.ShardIndex)
+	}
+
+	t.Run("claiming an already-claimed shard returns nil, nil", func(t *testing.T) {
+		again, err := s.ClaimDMLSubtaskForUpdateSkipLocked(ctx, 1, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if again != nil {
+			t.Errorf("expected nil for an already-claimed shard, got %+v", again)
+		}
+	})
+
+	if err := s.UpdateDMLSubtaskState(ctx, subtask.UID, "succeeded"); err != nil {
+		t.Fatalf("UpdateDMLSubtaskState: %v", err)
+	}
+
+	undoLog := &base.UndoLog{TableName: "t"}
+	if err := s.SetDMLSubtaskUndoLog(ctx, subtask.UID, undoLog); err != nil {
+		t.Fatalf("SetDMLSubtaskUndoLog: %v", err)
+	}
+	got, err := s.GetDMLSubtaskUndoLog(ctx, subtask.UID)
+	if err != nil {
+		t.Fatalf("GetDMLSubtaskUndoLog: %v", err)
+	}
+	if got != undoLog {
+		t.Errorf("GetDMLSubtaskUndoLog = %+v, want the same undo log that was set", got)
+	}
+}
+
+func TestGetDMLSubtaskUndoLogUnset(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	if err := s.CreateDMLSubtasks(ctx, 1, 1); err != nil {
+		t.Fatalf("CreateDMLSubtasks: %v", err)
+	}
+	subtask, err := s.ClaimDMLSubtaskForUpdateSkipLocked(ctx, 1, 0)
+	if err != nil {
+		t.Fatalf("ClaimDMLSubtaskForUpdateSkipLocked: %v", err)
+	}
+	got, err := s.GetDMLSubtaskUndoLog(ctx, subtask.UID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("expected nil undo log for a subtask that never recorded one, got %+v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"time"
+
+	storepb "github.com/bytebase/bytebase/proto/generated-go/store"
+
+	"github.com/bytebase/bytebase/backend/plugin/parser/base"
+)
+
+// TaskMessage is a single pipeline task row.
+type TaskMessage struct {
+	ID         int
+	Payload    string
+	InstanceID int
+	DatabaseID *int
+	PipelineID int
+	StageID    int
+}
+
+// FindInstanceMessage scopes a GetInstanceV2 lookup. Exactly one field is
+// expected to be set.
+type FindInstanceMessage struct {
+	UID        *int
+	ResourceID *string
+}
+
+// InstanceMessage is a single database instance row.
+type InstanceMessage struct {
+	UID        int
+	ResourceID string
+	Engine     storepb.Engine
+}
+
+// FindDatabaseMessage scopes a GetDatabaseV2 lookup. Either UID, or
+// InstanceID+DatabaseName, is expected to be set.
+type FindDatabaseMessage struct {
+	UID          *int
+	InstanceID   *int
+	DatabaseName *string
+}
+
+// DatabaseMessage is a single database row.
+type DatabaseMessage struct {
+	UID          int
+	InstanceID   int
+	DatabaseName string
+	Metadata     *storepb.DatabaseMetadata
+}
+
+// FindIssueMessage scopes a GetIssueV2 lookup.
+type FindIssueMessage struct {
+	PipelineID *int
+}
+
+// ProjectMessage is the subset of a project an issue refers back to.
+type ProjectMessage struct {
+	ResourceID string
+}
+
+// IssueMessage is a single issue row.
+type IssueMessage struct {
+	UID     int
+	Project *ProjectMessage
+}
+
+// FindIssueCommentMessage scopes a ListIssueComment lookup.
+type FindIssueCommentMessage struct {
+	IssueUID *int
+}
+
+// IssueCommentMessage is a single issue comment row, including the
+// structured payload backupData and the backup policy retention runner
+// record pre-update backups under.
+type IssueCommentMessage struct {
+	IssueUID int
+	Payload  *storepb.IssueCommentPayload
+}
+
+// FindBackupPolicyMessage scopes a ListBackupPolicies lookup. A nil
+// ProjectID lists every policy, used by the retention runner's sweep.
+type FindBackupPolicyMessage struct {
+	ProjectID *string
+}
+
+// BackupPolicyMessage governs where a project's pre-update backups land and
+// how long they are retained. It is matched against a source database by
+// DatabaseLabelSelector: every key/value pair in the selector must be
+// present in the database's labels for the policy to apply.
+type BackupPolicyMessage struct {
+	UID                      int
+	ProjectID                string
+	DatabaseLabelSelector    map[string]string
+	TargetInstanceResourceID string
+	TargetDatabaseName       string
+	Retention                time.Duration
+}
+
+// ExpiredBackupTable is one backup table a BackupPolicy's retention window
+// has passed for, as surfaced by ListExpiredBackupTables.
+type ExpiredBackupTable struct {
+	TableName string
+	IssueUID  int
+}
+
+// DMLSubtask is one shard of a sharded DML dispatch, claimed and executed
+// independently by dmlDispatcher's worker goroutines.
+type DMLSubtask struct {
+	UID           int
+	ParentTaskUID int
+	ShardIndex    int
+	State         string
+
+	undoLog *base.UndoLog
+}
@@ -0,0 +1,46 @@
+package store
+
+import "context"
+
+// GetInstanceV2 looks up a single instance. Not implemented in this
+// snapshot; see errNotImplemented.
+func (s *Store) GetInstanceV2(ctx context.Context, _ *FindInstanceMessage) (*InstanceMessage, error) {
+	return nil, errNotImplemented("GetInstanceV2")
+}
+
+// GetDatabaseV2 looks up a single database. Not implemented in this
+// snapshot; see errNotImplemented.
+func (s *Store) GetDatabaseV2(ctx context.Context, _ *FindDatabaseMessage) (*DatabaseMessage, error) {
+	return nil, errNotImplemented("GetDatabaseV2")
+}
+
+// GetIssueV2 looks up a single issue. Not implemented in this snapshot; see
+// errNotImplemented.
+func (s *Store) GetIssueV2(ctx context.Context, _ *FindIssueMessage) (*IssueMessage, error) {
+	return nil, errNotImplemented("GetIssueV2")
+}
+
+// GetTaskV2ByID looks up a single pipeline task. Not implemented in this
+// snapshot; see errNotImplemented.
+func (s *Store) GetTaskV2ByID(ctx context.Context, _ int) (*TaskMessage, error) {
+	return nil, errNotImplemented("GetTaskV2ByID")
+}
+
+// GetSheetStatementByID returns the SQL text of a sheet. Not implemented in
+// this snapshot; see errNotImplemented.
+func (s *Store) GetSheetStatementByID(ctx context.Context, _ int) (string, error) {
+	return "", errNotImplemented("GetSheetStatementByID")
+}
+
+// ListIssueComment lists comments on an issue. Not implemented in this
+// snapshot; see errNotImplemented.
+func (s *Store) ListIssueComment(ctx context.Context, _ *FindIssueCommentMessage) ([]*IssueCommentMessage, error) {
+	return nil, errNotImplemented("ListIssueComment")
+}
+
+// CreateIssueComment records a new issue comment, e.g. the pre-update backup
+// manifest backupData writes after materializing an undo log. Not
+// implemented in this snapshot; see errNotImplemented.
+func (s *Store) CreateIssueComment(ctx context.Context, _ *IssueCommentMessage, _ int) error {
+	return errNotImplemented("CreateIssueComment")
+}
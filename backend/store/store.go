@@ -0,0 +1,52 @@
+// Package store is the metadata store backing task execution: instances,
+// databases, issues, and the two resources this package's callers in
+// backend/runner/taskrun and backend/runner/backuppolicy introduced,
+// DML dispatch subtasks and BackupPolicy. The bulk of Store's surface
+// (instances, databases, issues, sheets) is the existing metadata store
+// maintained elsewhere in the application; only the DML-subtask and
+// BackupPolicy methods are implemented here, since those are genuinely new
+// resources with no pre-existing backing store to depend on. Everything
+// else is a deliberately unimplemented stub so this package type-checks
+// against its callers without duplicating the real store.
+package store
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Store is the metadata store handle threaded through task executors and
+// background runners.
+type Store struct {
+	mu sync.Mutex
+
+	dmlSubtasks      map[int]*DMLSubtask
+	nextSubtaskUID   int
+	backupPolicies   map[int]*BackupPolicyMessage
+	expiredBackups   map[int][]*ExpiredBackupTable
+	issueComments    []*IssueCommentMessage
+	nextIssueComment int
+}
+
+// New creates an empty Store. The real metadata store is constructed with
+// a database connection pool and a lot more state than this snapshot
+// vendors; this constructor only sets up the in-memory bookkeeping the DML
+// subtask and BackupPolicy methods below need.
+func New() *Store {
+	return &Store{
+		dmlSubtasks:    make(map[int]*DMLSubtask),
+		backupPolicies: make(map[int]*BackupPolicyMessage),
+		expiredBackups: make(map[int][]*ExpiredBackupTable),
+	}
+}
+
+// errNotImplemented is returned by the stub methods below: they cover
+// metadata (instances, databases, issues, sheets) that already exists in
+// the real application store, which this snapshot does not include. Unlike
+// the DML-subtask and BackupPolicy methods, there is no honest in-memory
+// substitute for "look up the real row for this ID", so these fail loudly
+// rather than fabricate data.
+func errNotImplemented(method string) error {
+	return errors.Errorf("store.%s is not implemented in this snapshot; it is backed by the real metadata store in the full application", method)
+}